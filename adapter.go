@@ -23,3 +23,103 @@ type Adapter interface {
 	Download(ctx context.Context, object string) (body io.ReadCloser, err error)                                     // 下载文件
 	GetInfo(ctx context.Context, object string) (info *File, err error)                                              // 获取指定文件信息
 }
+
+// BucketAdapter 使用存储桶的适配器，用于链路追踪
+type BucketAdapter interface {
+	Bucket() string // 存储桶名称
+}
+
+// ListResult 分页列出文件的结果
+type ListResult struct {
+	Files     []*File // 当前页的文件
+	NextToken string  // 下一页的起始标记，为空时表示没有更多文件
+}
+
+// PageLister 支持分页列出文件的适配器，文件按文件名的字节顺序返回，
+// 按目录遍历的适配器按目录层级顺序返回，见 pathOrderLister
+type PageLister interface {
+	ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) // 文件前缀，分页列出文件，token 为上一页返回的 NextToken
+}
+
+// pathOrderLister 按目录层级顺序（与 filepath.WalkDir 一致）分页的适配器，例如 "a/b" 在 "a.txt" 之前，
+// 分页结果不能和按文件名顺序的结果逐页合并
+type pathOrderLister interface {
+	pathOrder()
+}
+
+// DirLister 支持按目录列出文件的适配器
+type DirLister interface {
+	ListDir(ctx context.Context, prefix string) (files []*File, err error) // 列出目录下的文件和子目录，不递归
+}
+
+// RangeDownloader 支持分段下载的适配器
+type RangeDownloader interface {
+	DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) // 下载文件的指定范围，length 小于等于0时下载到文件末尾
+}
+
+// Copier 支持服务端复制文件的适配器
+type Copier interface {
+	Copy(ctx context.Context, src, dst string) (err error) // 复制文件
+}
+
+// Mover 支持服务端移动文件的适配器
+type Mover interface {
+	Move(ctx context.Context, src, dst string) (err error) // 移动文件
+}
+
+// Part 已上传的分片
+type Part struct {
+	Number int    `json:"number"` // 分片序号，从1开始
+	ETag   string `json:"etag"`   // 分片的ETag
+	Size   int64  `json:"size"`   // 分片大小
+}
+
+// MultipartUploader 支持分片上传的适配器
+type MultipartUploader interface {
+	InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error)                  // 初始化分片上传
+	UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) // 上传分片
+	CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error)                                 // 完成分片上传
+	AbortMultipart(ctx context.Context, object, uploadID string) (err error)                                                   // 取消分片上传
+}
+
+// UploadSignOptions 客户端直传的限制条件
+type UploadSignOptions struct {
+	ContentType string `json:"contentType"` // 上传文件的类型，签名后客户端必须使用该类型上传
+	MaxSize     int64  `json:"maxSize"`     // 上传文件的最大字节数，小于等于0时不限制，PUT 上传链接无法限制大小
+}
+
+// UploadSign 客户端直传需要的信息
+type UploadSign struct {
+	URL     string            `json:"url"`     // 上传地址
+	Method  string            `json:"method"`  // 请求方法，PUT 时请求体为文件内容，POST 时为 multipart/form-data 表单，文件放在 file 字段
+	Headers map[string]string `json:"headers"` // 上传时必须携带的header
+	Fields  map[string]string `json:"fields"`  // 表单上传时需要携带的字段
+	Expire  time.Time         `json:"expire"`  // 过期时间
+}
+
+// UploadSigner 支持生成客户端直传签名的适配器
+type UploadSigner interface {
+	GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) // 生成上传签名，expire 小于等于0时使用配置的有效期，opts 为 nil 时不做限制
+}
+
+// PostPolicyOptions 表单上传策略的限制条件
+type PostPolicyOptions struct {
+	Key         string `json:"key"`         // 上传的文件名，为空时使用 KeyPrefix
+	KeyPrefix   string `json:"keyPrefix"`   // 限制上传文件名的前缀，Key 为空时生效
+	Expire      int64  `json:"expire"`      // 有效期，单位秒，小于等于0时使用配置的有效期
+	ContentType string `json:"contentType"` // 上传文件的类型
+	MinSize     int64  `json:"minSize"`     // 上传文件的最小字节数
+	MaxSize     int64  `json:"maxSize"`     // 上传文件的最大字节数，小于等于0时不限制
+}
+
+// PostPolicyResult 表单上传需要的信息，客户端以 multipart/form-data 提交 Fields 和 file 字段
+type PostPolicyResult struct {
+	URL    string            `json:"url"`    // 上传地址
+	Fields map[string]string `json:"fields"` // 表单字段
+	Expire time.Time         `json:"expire"` // 过期时间
+}
+
+// PostPolicySigner 支持生成表单上传策略的适配器
+type PostPolicySigner interface {
+	PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) // 生成表单上传策略，opts 为 nil 时不做限制
+}
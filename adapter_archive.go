@@ -0,0 +1,546 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 归档文件格式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ArchiveAdapter 把 zip、tar、tar.gz 归档文件作为适配器使用。
+// 读取模式下只读，写入模式下 Upload 的文件依次写入新的归档文件，Close 时才保存，两种模式使用后都需要 Close
+type ArchiveAdapter struct {
+	format  string
+	file    *os.File // 读取模式下的归档文件，tar.gz 为解压后的 tar 文件
+	temp    string   // 关闭时需要删除的临时文件
+	mu      sync.RWMutex
+	entries map[string]*archiveEntry
+	names   []string // 按文件名排序的全部文件
+	writer  *archiveWriter
+	closed  bool
+}
+
+// archiveEntry 归档中的一个文件或目录
+type archiveEntry struct {
+	info    *File
+	zip     *zip.File
+	section *io.SectionReader // tar 文件的内容在归档中的位置
+}
+
+// archiveWriter 写入模式下正在生成的归档文件
+type archiveWriter struct {
+	zw     *zip.Writer
+	tw     *tar.Writer
+	gw     *gzip.Writer
+	buf    *bufio.Writer
+	commit func() error // 写入完成后保存归档文件
+	err    error        // 写入失败后归档文件已损坏，不再保存
+}
+
+// NewAdapterArchive 打开本地的归档文件，根据文件内容识别格式
+func NewAdapterArchive(name string) (*ArchiveAdapter, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	a, err := openArchive(file, "")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// NewAdapterStoreArchive 打开存储器中的归档文件，归档文件会先下载到临时目录
+func NewAdapterStoreArchive(ctx context.Context, store *Store, object string) (*ArchiveAdapter, error) {
+	body, err := store.Download(ctx, object)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	file, err := os.CreateTemp("", "filesys-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(file, body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	a, err := openArchive(file, file.Name())
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return a, nil
+}
+
+// NewArchiveWriter 在本地创建归档文件，根据扩展名确定格式，Close 时才会生成目标文件
+func NewArchiveWriter(name string) (*ArchiveAdapter, error) {
+	format, err := archiveFormatByName(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(filepath.Dir(name), ".filesys-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	return newArchiveWriter(file, format, func() (err error) {
+		if err = file.Chmod(0644); err != nil {
+			return
+		}
+		if err = file.Close(); err != nil {
+			return
+		}
+		return os.Rename(file.Name(), name)
+	}), nil
+}
+
+// NewStoreArchiveWriter 创建归档文件，Close 时上传到存储器，归档文件先生成在临时目录
+func NewStoreArchiveWriter(ctx context.Context, store *Store, object string) (*ArchiveAdapter, error) {
+	format, err := archiveFormatByName(object)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "filesys-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	return newArchiveWriter(file, format, func() (err error) {
+		var fileInfo os.FileInfo
+		if fileInfo, err = file.Stat(); err != nil {
+			return
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		return store.Upload(ctx, object, file, fileInfo.Size())
+	}), nil
+}
+
+// archiveFormatByName 根据扩展名确定归档格式
+func archiveFormatByName(name string) (string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return ArchiveTar, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	}
+	return "", gerror.Newf("无法根据文件名[%s]确定归档格式", name)
+}
+
+// openArchive 根据文件头识别格式并建立索引，temp 为关闭时需要删除的临时文件
+func openArchive(file *os.File, temp string) (a *ArchiveAdapter, err error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return
+	}
+	magic := make([]byte, 4)
+	n, _ := file.ReadAt(magic, 0)
+	magic = magic[:n]
+
+	a = &ArchiveAdapter{file: file, temp: temp, entries: make(map[string]*archiveEntry)}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		a.format = ArchiveZip
+		err = a.indexZip(fileInfo.Size())
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		a.format = ArchiveTarGz
+		err = a.indexTarGz()
+	default:
+		a.format = ArchiveTar
+		err = a.indexTar(file, fileInfo.Size())
+	}
+	if err != nil {
+		if a.file != file {
+			a.Close()
+		}
+		return nil, gerror.Wrapf(err, "读取%s归档文件失败", a.format)
+	}
+	a.sortNames()
+	return a, nil
+}
+
+func (a *ArchiveAdapter) indexZip(size int64) (err error) {
+	reader, err := zip.NewReader(a.file, size)
+	if err != nil {
+		return
+	}
+	for _, f := range reader.File {
+		a.addEntry(f.Name, &archiveEntry{
+			info: &File{
+				ModTime: f.Modified,
+				Size:    int64(f.UncompressedSize64),
+				IsDir:   f.FileInfo().IsDir(),
+			},
+			zip: f,
+		})
+	}
+	return
+}
+
+// indexTarGz 解压到临时文件后建立索引，gzip 不能随机读取
+func (a *ArchiveAdapter) indexTarGz() (err error) {
+	gr, err := gzip.NewReader(io.NewSectionReader(a.file, 0, 1<<63-1))
+	if err != nil {
+		return
+	}
+	defer gr.Close()
+	file, err := os.CreateTemp("", "filesys-archive-*")
+	if err != nil {
+		return
+	}
+	size, err := io.Copy(file, gr)
+	// 原始文件不再需要，换成解压后的文件
+	a.file.Close()
+	if a.temp != "" {
+		os.Remove(a.temp)
+	}
+	a.file, a.temp = file, file.Name()
+	if err != nil {
+		return
+	}
+	return a.indexTar(file, size)
+}
+
+// indexTar 记录每个文件的内容在归档中的位置，读取时不需要重新遍历
+func (a *ArchiveAdapter) indexTar(r io.ReaderAt, size int64) (err error) {
+	counter := &countReader{Reader: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(counter)
+	for {
+		var header *tar.Header
+		if header, err = tr.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return
+		}
+		mode := header.FileInfo().Mode()
+		if !mode.IsRegular() && !mode.IsDir() {
+			// 忽略链接、设备等特殊文件
+			continue
+		}
+		a.addEntry(header.Name, &archiveEntry{
+			info: &File{
+				ModTime: header.ModTime,
+				Size:    header.Size,
+				IsDir:   mode.IsDir(),
+			},
+			section: io.NewSectionReader(r, counter.n, header.Size),
+		})
+	}
+}
+
+// addEntry 添加文件，文件名统一为相对路径，不允许跳出归档的根目录
+func (a *ArchiveAdapter) addEntry(name string, entry *archiveEntry) {
+	if name = archiveName(name); name == "" {
+		return
+	}
+	entry.info.Name = name
+	entry.info.Header = map[string]string{}
+	if entry.info.IsDir {
+		entry.info.Size = 0
+	}
+	if _, ok := a.entries[name]; !ok {
+		a.names = append(a.names, name)
+	}
+	a.entries[name] = entry
+}
+
+func (a *ArchiveAdapter) sortNames() {
+	sort.Strings(a.names)
+}
+
+func newArchiveWriter(file *os.File, format string, commit func() error) *ArchiveAdapter {
+	a := &ArchiveAdapter{format: format, file: file, temp: file.Name(), entries: make(map[string]*archiveEntry)}
+	w := &archiveWriter{buf: bufio.NewWriter(file), commit: commit}
+	switch format {
+	case ArchiveZip:
+		w.zw = zip.NewWriter(w.buf)
+	case ArchiveTar:
+		w.tw = tar.NewWriter(w.buf)
+	case ArchiveTarGz:
+		w.gw = gzip.NewWriter(w.buf)
+		w.tw = tar.NewWriter(w.gw)
+	}
+	a.writer = w
+	return a
+}
+
+// Format 归档格式
+func (a *ArchiveAdapter) Format() string {
+	return a.format
+}
+
+// Close 读取模式下释放文件，写入模式下完成归档并保存，写入过程中出错时不保存并返回该错误
+func (a *ArchiveAdapter) Close() (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	defer func() {
+		if a.file != nil {
+			a.file.Close()
+		}
+		if a.temp != "" {
+			os.Remove(a.temp)
+		}
+	}()
+	if w := a.writer; w != nil {
+		if err = w.err; err != nil {
+			return
+		}
+		if w.zw != nil {
+			err = w.zw.Close()
+		} else if err = w.tw.Close(); err == nil && w.gw != nil {
+			err = w.gw.Close()
+		}
+		if err == nil {
+			err = w.buf.Flush()
+		}
+		if err == nil {
+			err = w.commit()
+		}
+	}
+	return
+}
+
+// readOnlyError 读取模式下不支持写入
+func (a *ArchiveAdapter) readOnlyError(op string) error {
+	return &storeError{kind: ErrUnsupported, err: gerror.Newf("归档文件不支持%s", op)}
+}
+
+// entry 获取文件，目录和不存在的文件返回 ErrNotExist
+func (a *ArchiveAdapter) entry(object string) (*archiveEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	entry, ok := a.entries[archiveName(object)]
+	if !ok || entry.info.IsDir {
+		return nil, notExistError(object)
+	}
+	return entry, nil
+}
+
+func (a *ArchiveAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = a.GetInfo(ctx, object)
+	return
+}
+
+// Upload 写入模式下把文件追加到归档中，以 "/" 结尾时创建目录，同名文件不能重复写入
+func (a *ArchiveAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w := a.writer
+	if w == nil {
+		return a.readOnlyError("上传文件")
+	}
+	if a.closed {
+		return gerror.New("归档文件已关闭")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	isDir := isDirKey(path)
+	name := archiveName(path)
+	if name == "" {
+		return invalidPathError(path)
+	}
+	if _, ok := a.entries[name]; ok {
+		return &storeError{kind: ErrAlreadyExists, err: gerror.Newf("文件[%s]已存在", name)}
+	}
+	info := &File{ModTime: time.Now(), Name: name, IsDir: isDir, Header: map[string]string{}}
+	if isDir {
+		err = w.writeDir(name, info.ModTime)
+	} else {
+		info.Size, err = w.writeFile(name, reader, size, info.ModTime)
+	}
+	if err != nil {
+		// 写了一半的文件无法撤销，归档文件已损坏
+		w.err = gerror.Wrapf(err, "写入文件[%s]失败，归档文件已损坏", name)
+		return w.err
+	}
+	a.entries[name] = &archiveEntry{info: info}
+	a.names = append(a.names, name)
+	a.sortNames()
+	return
+}
+
+func (w *archiveWriter) writeDir(name string, modTime time.Time) (err error) {
+	if w.zw != nil {
+		_, err = w.zw.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: modTime})
+		return
+	}
+	return w.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755, ModTime: modTime})
+}
+
+func (w *archiveWriter) writeFile(name string, reader io.Reader, size int64, modTime time.Time) (written int64, err error) {
+	if w.zw != nil {
+		var fw io.Writer
+		if fw, err = w.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}); err != nil {
+			return
+		}
+		if size >= 0 {
+			reader = io.LimitReader(reader, size)
+		}
+		return io.Copy(fw, reader)
+	}
+	if size < 0 {
+		// tar 需要先写入文件大小，大小未知时先保存到临时文件
+		var tmp *os.File
+		if tmp, err = os.CreateTemp("", "filesys-archive-*"); err != nil {
+			return
+		}
+		defer func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}()
+		if size, err = io.Copy(tmp, reader); err != nil {
+			return
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		reader = tmp
+	}
+	if err = w.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size, ModTime: modTime}); err != nil {
+		return
+	}
+	return io.CopyN(w.tw, reader, size)
+}
+
+func (a *ArchiveAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	return a.readOnlyError("删除文件")
+}
+
+func (a *ArchiveAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	return "", a.readOnlyError("生成访问链接")
+}
+
+func (a *ArchiveAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return a.DownloadRange(ctx, object, 0, 0)
+}
+
+func (a *ArchiveAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	if a.writer != nil {
+		return nil, &storeError{kind: ErrUnsupported, err: gerror.New("写入模式下不能读取文件")}
+	}
+	entry, err := a.entry(object)
+	if err != nil {
+		return
+	}
+	if offset > entry.info.Size {
+		offset = entry.info.Size
+	}
+	if entry.section != nil {
+		return limitBody(io.NopCloser(io.NewSectionReader(entry.section, offset, entry.info.Size-offset)), length), nil
+	}
+	if entry.zip.Method == zip.Store {
+		// 未压缩的文件可以直接定位
+		var dataOffset int64
+		if dataOffset, err = entry.zip.DataOffset(); err != nil {
+			return
+		}
+		return limitBody(io.NopCloser(io.NewSectionReader(a.file, dataOffset+offset, entry.info.Size-offset)), length), nil
+	}
+	if body, err = entry.zip.Open(); err != nil {
+		return
+	}
+	if offset > 0 {
+		if _, err = io.CopyN(io.Discard, body, offset); err != nil {
+			body.Close()
+			return nil, err
+		}
+	}
+	return limitBody(body, length), nil
+}
+
+// GetInfo 获取文件信息，归档中记录的目录也可以获取
+func (a *ArchiveAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	entry, ok := a.entries[archiveName(object)]
+	if !ok {
+		return nil, notExistError(object)
+	}
+	info = &File{}
+	*info = *entry.info
+	return
+}
+
+func (a *ArchiveAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, a, prefix)
+}
+
+// ListPage 按文件名顺序列出文件，不包含目录
+func (a *ArchiveAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	result = &ListResult{}
+	start := sort.SearchStrings(a.names, prefix)
+	if token > prefix {
+		start = sort.Search(len(a.names), func(i int) bool {
+			return a.names[i] > token
+		})
+	}
+	for _, name := range a.names[start:] {
+		if !strings.HasPrefix(name, prefix) {
+			break
+		}
+		entry := a.entries[name]
+		if entry.info.IsDir {
+			continue
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			break
+		}
+		info := &File{}
+		*info = *entry.info
+		result.Files = append(result.Files, info)
+	}
+	return
+}
+
+// archiveName 把路径转换为归档中的文件名，不允许跳出归档的根目录
+func archiveName(object string) string {
+	return strings.TrimPrefix(path.Clean("/"+object), "/")
+}
+
+// countReader 记录已经读取的字节数
+type countReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.n += int64(n)
+	return
+}
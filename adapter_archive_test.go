@@ -0,0 +1,211 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// archiveTestFiles 写入归档的文件，长文件名需要 PAX 扩展头，内容跨越多个 tar 块
+var archiveTestFiles = map[string]string{
+	"a.txt":     "hello",
+	"dir/b.txt": strings.Repeat("0123456789", 100),
+	"dir/sub/c": "",
+	"long/" + strings.Repeat("x", 120) + ".txt": "long name",
+}
+
+func TestArchiveAdapterRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []string{ArchiveZip, ArchiveTar, ArchiveTarGz} {
+		t.Run(format, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "test."+format)
+			writer, err := NewArchiveWriter(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if writer.Format() != format {
+				t.Errorf("Format = %s, want %s", writer.Format(), format)
+			}
+			if err = writer.Upload(ctx, "dir/", nil, 0); err != nil {
+				t.Fatalf("Upload dir: %v", err)
+			}
+			for object, content := range archiveTestFiles {
+				// 大小未知时 tar 先保存到临时文件
+				size := int64(len(content))
+				if object == "a.txt" {
+					size = -1
+				}
+				if err = writer.Upload(ctx, object, strings.NewReader(content), size); err != nil {
+					t.Fatalf("Upload(%s): %v", object, err)
+				}
+			}
+			if err = writer.Upload(ctx, "/a.txt", strings.NewReader("again"), 5); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("Upload duplicate: got %v, want ErrAlreadyExists", err)
+			}
+			if _, err = writer.Download(ctx, "a.txt"); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Download in write mode: got %v, want ErrUnsupported", err)
+			}
+			// Close 之前不生成目标文件
+			if _, err = os.Stat(name); !os.IsNotExist(err) {
+				t.Fatalf("archive exists before Close: %v", err)
+			}
+			if err = writer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if err = writer.Close(); err != nil {
+				t.Fatalf("second Close: %v", err)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(name), ".filesys-archive-*")); len(matches) > 0 {
+				t.Errorf("temporary files left: %v", matches)
+			}
+
+			reader, err := NewAdapterArchive(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if reader.Format() != format {
+				t.Errorf("Format = %s, want %s", reader.Format(), format)
+			}
+			checkArchive(t, reader, archiveTestFiles)
+			if info, err := reader.GetInfo(ctx, "dir"); err != nil || !info.IsDir {
+				t.Errorf("GetInfo(dir) = %+v, %v", info, err)
+			}
+			if err = reader.Upload(ctx, "new.txt", strings.NewReader("new"), 3); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Upload in read mode: got %v, want ErrUnsupported", err)
+			}
+		})
+	}
+}
+
+// TestArchiveAdapterZipStore 未压缩的文件直接按 DataOffset 定位读取
+func TestArchiveAdapterZipStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "store.zip")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	files := map[string]string{"a.txt": "stored content", "b.txt": "deflated content"}
+	for _, object := range []string{"a.txt", "b.txt"} {
+		method := zip.Store
+		if object == "b.txt" {
+			method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: object, Method: method, Comment: strings.Repeat("c", 50)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, files[object])
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	reader, err := NewAdapterArchive(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if entry, _ := reader.entry("a.txt"); entry.zip.Method != zip.Store {
+		t.Fatalf("a.txt method = %d, want zip.Store", entry.zip.Method)
+	}
+	checkArchive(t, reader, files)
+}
+
+// TestArchiveAdapterTarSpecial 忽略链接等特殊文件，跳出根目录的文件名归入根目录
+func TestArchiveAdapterTarSpecial(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "special.tar")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(file)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "a.txt"})
+	for _, object := range []string{"../a.txt", "./b.txt"} {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: object, Mode: 0644, Size: int64(len(object))})
+		io.WriteString(tw, object)
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	reader, err := NewAdapterArchive(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	checkArchive(t, reader, map[string]string{"a.txt": "../a.txt", "b.txt": "./b.txt"})
+}
+
+// checkArchive 检查归档中的文件列表和内容，包括分页和部分读取
+func checkArchive(t *testing.T, reader *ArchiveAdapter, files map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	var want []string
+	for object := range files {
+		want = append(want, object)
+	}
+	sort.Strings(want)
+	var (
+		got   []string
+		token string
+	)
+	for page := 0; page <= len(want); page++ {
+		result, err := reader.ListPage(ctx, "", token, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fileNames(result.Files)...)
+		if token = result.NextToken; token == "" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPage = %v, want %v", got, want)
+	}
+
+	for object, content := range files {
+		info, err := reader.GetInfo(ctx, object)
+		if err != nil || info.Size != int64(len(content)) {
+			t.Errorf("GetInfo(%s) = %+v, %v", object, info, err)
+		}
+		if got := readObject(t, reader, object); got != content {
+			t.Errorf("Download(%s) = %q, want %q", object, got, content)
+		}
+		if len(content) < 4 {
+			continue
+		}
+		body, err := reader.DownloadRange(ctx, object, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != content[2:4] {
+			t.Errorf("DownloadRange(%s, 2, 2) = %q, want %q", object, data, content[2:4])
+		}
+		body, err = reader.DownloadRange(ctx, object, 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ = io.ReadAll(body)
+		body.Close()
+		if string(data) != content[1:] {
+			t.Errorf("DownloadRange(%s, 1, 0) = %q, want %q", object, data, content[1:])
+		}
+	}
+	if _, err := reader.Download(ctx, "missing.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Download missing file: got %v, want ErrNotExist", err)
+	}
+}
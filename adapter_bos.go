@@ -2,9 +2,14 @@ package filesys
 
 import (
 	"context"
-	"fmt"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/baidubce/bce-sdk-go/bce"
 	"github.com/baidubce/bce-sdk-go/services/bos"
 	"github.com/baidubce/bce-sdk-go/services/bos/api"
+	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"io"
@@ -52,6 +57,11 @@ func NewAdapterBos(i interface{}) (Adapter, error) {
 	return b, err
 }
 
+// Bucket 存储桶名称
+func (b *BosAdapter) Bucket() string {
+	return b.config.Bucket
+}
+
 func (b *BosAdapter) IsExist(ctx context.Context, object string) (err error) {
 	_, err = b.GetInfo(ctx, object)
 	return
@@ -75,8 +85,16 @@ func (b *BosAdapter) Upload(ctx context.Context, path string, reader io.Reader,
 			}
 		}
 	}
-	_, err = b.client.PutObjectFromStream(b.config.Bucket, objectRel(path), reader, args)
-	return
+	if size < 0 {
+		_, err = b.client.PutObjectFromStream(b.config.Bucket, objectRel(path), reader, args)
+		return bosError(err)
+	}
+	body, err := bce.NewBodyFromSizedReader(reader, size)
+	if err != nil {
+		return
+	}
+	_, err = b.client.PutObject(b.config.Bucket, objectRel(path), body, args)
+	return bosError(err)
 }
 
 func (b *BosAdapter) Delete(ctx context.Context, objects ...string) (err error) {
@@ -86,11 +104,19 @@ func (b *BosAdapter) Delete(ctx context.Context, objects ...string) (err error)
 	for idx, object := range objects {
 		objects[idx] = objectRel(object)
 	}
-	res, _ := b.client.DeleteMultipleObjectsFromKeyList(b.config.Bucket, objects)
-	if res != nil && len(res.Errors) > 0 {
-		err = fmt.Errorf("%+v", res)
+	res, err := b.client.DeleteMultipleObjectsFromKeyList(b.config.Bucket, objects)
+	if err == io.EOF {
+		// 全部删除成功时响应体为空
+		return nil
 	}
-	return
+	if err != nil {
+		return bosError(err)
+	}
+	var errs []error
+	for _, item := range res.Errors {
+		errs = append(errs, bosDeleteError(item))
+	}
+	return joinErrors(errs...)
 }
 
 func (b *BosAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -111,10 +137,63 @@ func (b *BosAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	return
 }
 
+// GetUploadSignURL 生成 PUT 上传链接，指定 ContentType 时会参与签名
+func (b *BosAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	exp := uploadExpire(expire, b.config.Expire)
+	headers := map[string]string{}
+	if opts.ContentType != "" {
+		headers["Content-Type"] = opts.ContentType
+	}
+	link := b.client.GeneratePresignedUrl(b.config.Bucket, objectRel(object), int(exp), http.MethodPut, headers, nil)
+	return newUploadSign(link, exp, opts), nil
+}
+
+// PostPolicy https://cloud.baidu.com/doc/BOS/s/Ekc4epvx6
+func (b *BosAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var encoded string
+	exp := uploadExpire(opts.Expire, b.config.Expire)
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+	conditions := policyConditions(opts, map[string]string{"bucket": b.config.Bucket})
+	if _, encoded, err = encodePolicy(deadline, conditions); err != nil {
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(b.config.SecretKey))
+	mac.Write([]byte(encoded))
+
+	fields := policyFields(opts)
+	fields["accessKey"] = b.config.AccessKey
+	fields["policy"] = encoded
+	fields["signature"] = hex.EncodeToString(mac.Sum(nil))
+	return &PostPolicyResult{
+		URL:    bucketEndpoint(b.config.Bucket, b.config.Endpoint),
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
 func (b *BosAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	result, err := b.client.GetObject(b.config.Bucket, objectRel(object), nil)
 	if err != nil {
-		return
+		return nil, bosError(err)
+	}
+	body = result.Body
+	return
+}
+
+func (b *BosAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	ranges := []int64{offset}
+	if length > 0 {
+		ranges = append(ranges, offset+length-1)
+	}
+	result, err := b.client.GetObject(b.config.Bucket, objectRel(object), nil, ranges...)
+	if err != nil {
+		return nil, bosError(err)
 	}
 	body = result.Body
 	return
@@ -124,37 +203,140 @@ func (b *BosAdapter) GetInfo(ctx context.Context, object string) (info *File, er
 	var resp *api.GetObjectMetaResult
 	resp, err = b.client.GetObjectMeta(b.config.Bucket, objectRel(object))
 	if err != nil {
-		return
+		return nil, bosError(err)
 	}
 	info = &File{
 		Name:   objectRel(object),
 		Size:   resp.ContentLength,
-		IsDir:  resp.ContentLength == 0,
+		IsDir:  isDirKey(object),
 		Header: resp.UserMeta,
 	}
 	info.ModTime, _ = time.Parse(http.TimeFormat, resp.LastModified)
 	return
 }
 
+func (b *BosAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	_, err = b.client.BasicCopyObject(b.config.Bucket, objectRel(dst), b.config.Bucket, objectRel(src))
+	return bosError(err)
+}
+
+func (b *BosAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	var (
+		contentType string
+		res         *api.InitiateMultipartUploadResult
+	)
+	args := &api.InitiateMultipartUploadArgs{}
+	for k, v := range headers {
+		switch strings.ToLower(k) {
+		case "content-disposition":
+			args.ContentDisposition = v
+		case "content-type":
+			contentType = v
+		}
+	}
+	res, err = b.client.InitiateMultipartUpload(b.config.Bucket, objectRel(object), contentType, args)
+	if err != nil {
+		return "", bosError(err)
+	}
+	return res.UploadId, nil
+}
+
+func (b *BosAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	var (
+		body *bce.Body
+		etag string
+	)
+	if body, err = bce.NewBodyFromSizedReader(reader, size); err != nil {
+		return
+	}
+	etag, err = b.client.BasicUploadPart(b.config.Bucket, objectRel(object), uploadID, number, body)
+	if err != nil {
+		return nil, bosError(err)
+	}
+	return &Part{Number: number, ETag: etag, Size: size}, nil
+}
+
+func (b *BosAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	args := &api.CompleteMultipartUploadArgs{}
+	for _, part := range parts {
+		args.Parts = append(args.Parts, api.UploadInfoType{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err = b.client.CompleteMultipartUploadFromStruct(b.config.Bucket, objectRel(object), uploadID, args)
+	return bosError(err)
+}
+
+func (b *BosAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	return bosError(b.client.AbortMultipartUpload(b.config.Bucket, objectRel(object), uploadID))
+}
+
 func (b *BosAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, b, prefix)
+}
+
+func (b *BosAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return b.list(ctx, prefix, "", token, limit)
+}
+
+func (b *BosAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, b.list)
+}
+
+func (b *BosAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
 	var resp *api.ListObjectsResult
 	args := &api.ListObjectsArgs{
-		Prefix:  objectRel(prefix),
-		MaxKeys: 1000,
+		Prefix:    objectRel(prefix),
+		Delimiter: delimiter,
+		Marker:    token,
+		MaxKeys:   limit,
 	}
 	resp, err = b.client.ListObjects(b.config.Bucket, args)
 	if err != nil {
-		return
+		return nil, bosError(err)
 	}
 
+	result = &ListResult{}
+	lastKey := ""
 	for _, object := range resp.Contents {
 		file := &File{
 			Size:  int64(object.Size),
 			Name:  objectRel(object.Key),
-			IsDir: object.Size == 0,
+			IsDir: isDirKey(object.Key),
 		}
-		file.ModTime, _ = time.Parse(http.TimeFormat, object.LastModified)
-		files = append(files, file)
+		file.ModTime, _ = time.Parse(time.RFC3339, object.LastModified)
+		result.Files = append(result.Files, file)
+		lastKey = object.Key
+	}
+	for _, commonPrefix := range resp.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix.Prefix))
 	}
+	result.NextToken = nextMarker(resp.IsTruncated, resp.NextMarker, lastKey)
 	return
 }
+
+// bosError 对百度云返回的错误进行归类
+func bosError(err error) error {
+	var respErr *bce.BceServiceError
+	if errors.As(err, &respErr) {
+		return statusError(respErr.StatusCode, err)
+	}
+	return err
+}
+
+// bosDeleteError 对批量删除中单个文件的错误码进行归类
+func bosDeleteError(item api.DeleteObjectResult) error {
+	err := gerror.Newf("%s: %s %s", item.Key, item.Code, item.Message)
+	switch item.Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return wrapError(ErrNotExist, err)
+	case "AccessDenied":
+		return wrapError(ErrPermission, err)
+	case "InvalidObjectName":
+		return wrapError(ErrInvalidPath, err)
+	case "InternalError", "ServiceUnavailable", "RequestTimeout", "SlowDown":
+		return temporaryError(err)
+	}
+	return err
+}
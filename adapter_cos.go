@@ -2,7 +2,8 @@ package filesys
 
 import (
 	"context"
-	"errors"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
@@ -68,42 +69,33 @@ func NewAdapterCos(i interface{}) (Adapter, error) {
 	return c, nil
 }
 
+// Bucket 存储桶名称，包含 AppId
+func (c *CosAdapter) Bucket() string {
+	return c.config.Bucket + "-" + c.config.AppId
+}
+
 func (c *CosAdapter) IsExist(ctx context.Context, object string) (err error) {
-	_, err = c.GetInfo(ctx, object)
-	return
+	_, err = c.client.Object.Head(ctx, objectRel(object), nil)
+	return cosError(err)
 }
 
 func (c *CosAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
-	objHeader := &cos.ObjectPutHeaderOptions{}
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-encoding":
-				objHeader.ContentEncoding = v
-			case "content-type":
-				objHeader.ContentType = v
-			case "content-disposition":
-				objHeader.ContentDisposition = v
-			}
-		}
+	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: cosHeaderOptions(headers...)}
+	if size > 0 {
+		// 中间件包装后SDK无法从 reader 的类型获取长度
+		opt.ContentLength = size
 	}
-	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: objHeader}
-	_, err = c.client.Object.Put(context.Background(), objectRel(path), reader, opt)
-	return
+	_, err = c.client.Object.Put(ctx, objectRel(path), reader, opt)
+	return cosError(err)
 }
 
 func (c *CosAdapter) Delete(ctx context.Context, objects ...string) (err error) {
-	var errs []string
+	var errs []error
 	for _, object := range objects {
-		_, err = c.client.Object.Delete(context.Background(), objectRel(object))
-		if err != nil {
-			errs = append(errs, err.Error())
-		}
-	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
+		_, err = c.client.Object.Delete(ctx, objectRel(object))
+		errs = append(errs, cosError(err))
 	}
-	return
+	return joinErrors(errs...)
 }
 
 func (c *CosAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -117,12 +109,12 @@ func (c *CosAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	}
 
 	var u *url.URL
-	u, err = c.client.Object.GetPresignedURL(context.Background(),
+	u, err = c.client.Object.GetPresignedURL(ctx,
 		http.MethodGet, objectRel(object),
 		c.config.AccessKey, c.config.SecretKey,
 		time.Duration(exp)*time.Second, nil)
 	if err != nil {
-		return
+		return "", cosError(err)
 	}
 	link = u.String()
 	if !strings.HasPrefix(link, c.config.Domain) {
@@ -131,10 +123,82 @@ func (c *CosAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	return
 }
 
+// GetUploadSignURL 生成 PUT 上传链接，指定 ContentType 时会参与签名
+func (c *CosAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	var u *url.URL
+	exp := uploadExpire(expire, c.config.Expire)
+	header := &http.Header{}
+	if opts.ContentType != "" {
+		header.Set("Content-Type", opts.ContentType)
+	}
+	u, err = c.client.Object.GetPresignedURL(ctx,
+		http.MethodPut, objectRel(object),
+		c.config.AccessKey, c.config.SecretKey,
+		time.Duration(exp)*time.Second, &cos.PresignedURLOptions{Header: header})
+	if err != nil {
+		return nil, cosError(err)
+	}
+	return newUploadSign(u.String(), exp, opts), nil
+}
+
+// PostPolicy https://cloud.tencent.com/document/product/436/14690
+func (c *CosAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var (
+		origin  []byte
+		encoded string
+	)
+	exp := uploadExpire(opts.Expire, c.config.Expire)
+	now := time.Now()
+	deadline := now.Add(time.Duration(exp) * time.Second)
+	keyTime := fmt.Sprintf("%d;%d", now.Unix(), deadline.Unix())
+	conditions := policyConditions(opts,
+		map[string]string{"bucket": c.config.Bucket + "-" + c.config.AppId},
+		map[string]string{"q-sign-algorithm": "sha1"},
+		map[string]string{"q-ak": c.config.AccessKey},
+		map[string]string{"q-sign-time": keyTime},
+	)
+	if origin, encoded, err = encodePolicy(deadline, conditions); err != nil {
+		return
+	}
+	signKey := hex.EncodeToString(hmacSha1(c.config.SecretKey, keyTime))
+	stringToSign := fmt.Sprintf("%x", sha1.Sum(origin))
+
+	fields := policyFields(opts)
+	fields["policy"] = encoded
+	fields["q-sign-algorithm"] = "sha1"
+	fields["q-ak"] = c.config.AccessKey
+	fields["q-key-time"] = keyTime
+	fields["q-signature"] = hex.EncodeToString(hmacSha1(signKey, stringToSign))
+	fields["success_action_status"] = "200"
+	return &PostPolicyResult{
+		URL:    c.client.BaseURL.BucketURL.String(),
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
 func (c *CosAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	result, err := c.client.Object.Get(ctx, objectRel(object), nil)
 	if err != nil {
-		return
+		return nil, cosError(err)
+	}
+	body = result.Body
+	return
+}
+
+func (c *CosAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	opt := &cos.ObjectGetOptions{
+		Range: httpRange(offset, length),
+	}
+	result, err := c.client.Object.Get(ctx, objectRel(object), opt)
+	if err != nil {
+		return nil, cosError(err)
 	}
 	body = result.Body
 	return
@@ -143,13 +207,10 @@ func (c *CosAdapter) Download(ctx context.Context, object string) (body io.ReadC
 func (c *CosAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
 	var resp *cos.Response
 	path := objectRel(object)
-	resp, err = c.client.Object.Get(ctx, path, nil)
+	resp, err = c.client.Object.Head(ctx, path, nil)
 	if err != nil {
-		return
+		return nil, cosError(err)
 	}
-	defer func() {
-		resp.Body.Close()
-	}()
 	header := make(map[string]string)
 	for k := range resp.Header {
 		header[k] = resp.Header.Get(k)
@@ -160,11 +221,119 @@ func (c *CosAdapter) GetInfo(ctx context.Context, object string) (info *File, er
 	}
 	info.ModTime, _ = time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
 	info.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
-	info.IsDir = info.Size == 0
+	info.IsDir = isDirKey(path)
 	return
 }
 
+func (c *CosAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	sourceURL := fmt.Sprintf("%s/%s", c.client.BaseURL.BucketURL.Host, objectRel(src))
+	_, _, err = c.client.Object.Copy(ctx, objectRel(dst), sourceURL, nil)
+	return cosError(err)
+}
+
+func (c *CosAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	var res *cos.InitiateMultipartUploadResult
+	opt := &cos.InitiateMultipartUploadOptions{ObjectPutHeaderOptions: cosHeaderOptions(headers)}
+	res, _, err = c.client.Object.InitiateMultipartUpload(ctx, objectRel(object), opt)
+	if err != nil {
+		return "", cosError(err)
+	}
+	return res.UploadID, nil
+}
+
+func (c *CosAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	var resp *cos.Response
+	opt := &cos.ObjectUploadPartOptions{ContentLength: size}
+	resp, err = c.client.Object.UploadPart(ctx, objectRel(object), uploadID, number, reader, opt)
+	if err != nil {
+		return nil, cosError(err)
+	}
+	return &Part{Number: number, ETag: resp.Header.Get("ETag"), Size: size}, nil
+}
+
+func (c *CosAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	opt := &cos.CompleteMultipartUploadOptions{}
+	for _, part := range parts {
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, _, err = c.client.Object.CompleteMultipartUpload(ctx, objectRel(object), uploadID, opt)
+	return cosError(err)
+}
+
+func (c *CosAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	_, err = c.client.Object.AbortMultipartUpload(ctx, objectRel(object), uploadID)
+	return cosError(err)
+}
+
 func (c *CosAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	// TODO: 腾讯云的SDK中暂时没开放这个功能
+	return listAll(ctx, c, prefix)
+}
+
+func (c *CosAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return c.list(ctx, prefix, "", token, limit)
+}
+
+func (c *CosAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, c.list)
+}
+
+func (c *CosAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
+	var res *cos.BucketGetResult
+	opt := &cos.BucketGetOptions{
+		Prefix:    objectRel(prefix),
+		Delimiter: delimiter,
+		Marker:    token,
+		MaxKeys:   limit,
+	}
+	res, _, err = c.client.Bucket.Get(ctx, opt)
+	if err != nil {
+		return nil, cosError(err)
+	}
+	result = &ListResult{}
+	lastKey := ""
+	for _, object := range res.Contents {
+		file := &File{
+			Name:   objectRel(object.Key),
+			Size:   object.Size,
+			IsDir:  isDirKey(object.Key),
+			Header: map[string]string{},
+		}
+		file.ModTime, _ = time.Parse(time.RFC3339, object.LastModified)
+		result.Files = append(result.Files, file)
+		lastKey = object.Key
+	}
+	for _, commonPrefix := range res.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix))
+	}
+	result.NextToken = nextMarker(res.IsTruncated, res.NextMarker, lastKey)
 	return
 }
+
+// cosHeaderOptions 把header转换为腾讯云的请求参数
+func cosHeaderOptions(headers ...map[string]string) *cos.ObjectPutHeaderOptions {
+	objHeader := &cos.ObjectPutHeaderOptions{}
+	for _, header := range headers {
+		for k, v := range header {
+			switch strings.ToLower(k) {
+			case "content-encoding":
+				objHeader.ContentEncoding = v
+			case "content-type":
+				objHeader.ContentType = v
+			case "content-disposition":
+				objHeader.ContentDisposition = v
+			}
+		}
+	}
+	return objHeader
+}
+
+// cosError 对腾讯云返回的错误进行归类
+func cosError(err error) error {
+	if respErr, ok := cos.IsCOSError(err); ok && respErr.Response != nil {
+		return statusError(respErr.Response.StatusCode, err)
+	}
+	return err
+}
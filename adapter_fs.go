@@ -0,0 +1,233 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+type ConfigFS struct {
+	Domain string `json:"domain"` // 访问域名，为空时不支持 GetSignURL
+}
+
+// FSAdapter 把任意的 fs.FS（例如 embed.FS）包装为只读的适配器，写入操作返回 ErrUnsupported
+type FSAdapter struct {
+	config *ConfigFS
+	fsys   fs.FS
+}
+
+// NewAdapterFS 实例化只读的 fs.FS 适配器，cfg 可选
+func NewAdapterFS(fsys fs.FS, cfg ...*ConfigFS) (Adapter, error) {
+	if fsys == nil {
+		return nil, gerror.New("fsys不能为空")
+	}
+	config := &ConfigFS{}
+	if len(cfg) > 0 && cfg[0] != nil {
+		config = cfg[0]
+	}
+	return &FSAdapter{config: config, fsys: fsys}, nil
+}
+
+// name 把文件路径转换为 fs.FS 中的名称
+func (a *FSAdapter) name(object string) (string, error) {
+	name := path.Clean("/" + objectRel(object))[1:]
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", invalidPathError(object)
+	}
+	return name, nil
+}
+
+// readOnlyError 生成只读适配器不支持写入的错误
+func (a *FSAdapter) readOnlyError(op string) error {
+	return &storeError{kind: ErrUnsupported, err: gerror.Newf("只读适配器不支持%s", op)}
+}
+
+func (a *FSAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = a.GetInfo(ctx, object)
+	return
+}
+
+func (a *FSAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return a.readOnlyError("上传文件")
+}
+
+func (a *FSAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	return a.readOnlyError("删除文件")
+}
+
+func (a *FSAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	return a.readOnlyError("复制文件")
+}
+
+func (a *FSAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	return a.readOnlyError("移动文件")
+}
+
+func (a *FSAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	if a.config.Domain == "" {
+		return "", &storeError{kind: ErrUnsupported, err: gerror.New("未配置Domain，无法生成访问链接")}
+	}
+	link = gfile.Join(a.config.Domain, object)
+	return
+}
+
+func (a *FSAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return a.DownloadRange(ctx, object, 0, 0)
+}
+
+func (a *FSAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	name, err := a.name(object)
+	if err != nil {
+		return
+	}
+	file, err := a.fsys.Open(name)
+	if err != nil {
+		return nil, a.notExist(object, err)
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+	if fileInfo.IsDir() {
+		file.Close()
+		return nil, notExistError(object)
+	}
+	if offset > 0 {
+		if seeker, ok := file.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		} else if _, err = io.CopyN(io.Discard, file, offset); err == io.EOF {
+			err = nil
+		}
+		if err != nil {
+			file.Close()
+			return
+		}
+	}
+	return limitBody(file, length), nil
+}
+
+func (a *FSAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	name, err := a.name(object)
+	if err != nil {
+		return
+	}
+	fileInfo, err := fs.Stat(a.fsys, name)
+	if err != nil {
+		return nil, a.notExist(object, err)
+	}
+	return &File{
+		ModTime: fileInfo.ModTime(),
+		Name:    objectRel(object),
+		Size:    fileInfo.Size(),
+		IsDir:   fileInfo.IsDir(),
+		Header:  map[string]string{},
+	}, nil
+}
+
+func (a *FSAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, a, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (a *FSAdapter) pathOrder() {}
+
+// ListPage 按 fs.WalkDir 的顺序遍历，token 为上一页最后一个文件的相对路径
+func (a *FSAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	result = &ListResult{}
+	// 只需要遍历前缀所在的目录
+	root, err := a.name(path.Dir(prefix))
+	if err != nil {
+		return
+	}
+	err = fs.WalkDir(a.fsys, root, func(name string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			if errors.Is(errWalk, fs.ErrNotExist) && name == root {
+				return fs.SkipDir
+			}
+			return errWalk
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// 跳过在之前的分页中已经遍历完的目录
+			if token != "" && name != "." && comparePath(name, token) < 0 && !strings.HasPrefix(token, name+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(name, prefix) || (token != "" && comparePath(name, token) <= 0) {
+			return nil
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			return errStopWalk
+		}
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result.Files = append(result.Files, &File{
+			ModTime: fileInfo.ModTime(),
+			Name:    name,
+			Size:    fileInfo.Size(),
+			IsDir:   false,
+			Header:  map[string]string{},
+		})
+		return nil
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return
+}
+
+func (a *FSAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	dir := strings.TrimSuffix(dirPrefix(prefix), "/")
+	name, err := a.name(dir)
+	if err != nil {
+		return
+	}
+	entries, err := fs.ReadDir(a.fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return
+	}
+	for _, entry := range entries {
+		var fileInfo fs.FileInfo
+		if fileInfo, err = entry.Info(); err != nil {
+			return nil, err
+		}
+		files = append(files, &File{
+			ModTime: fileInfo.ModTime(),
+			Name:    path.Join(dir, entry.Name()),
+			Size:    fileInfo.Size(),
+			IsDir:   entry.IsDir(),
+			Header:  map[string]string{},
+		})
+	}
+	return
+}
+
+// notExist 文件不存在时返回统一的错误
+func (a *FSAdapter) notExist(object string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return notExistError(object)
+	}
+	return err
+}
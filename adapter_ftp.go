@@ -0,0 +1,563 @@
+package filesys
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+	"github.com/gogf/gf/v2/util/gvalid"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+const (
+	FtpTLSExplicit = "explicit" // 显式TLS，连接后通过 AUTH TLS 升级
+	FtpTLSImplicit = "implicit" // 隐式TLS，连接时直接使用TLS，默认端口990
+)
+
+type ConfigFtp struct {
+	Host               string `json:"host" v:"required#Host不能为空"`
+	Port               int    `json:"port"`
+	Username           string `json:"username"` // 为空时使用 anonymous 登录
+	Password           string `json:"password"`
+	TLS                string `json:"tls"`                // TLS模式，explicit 或 implicit，为空时不使用TLS
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"` // 不校验服务器证书，仅用于测试环境
+	DisableEPSV        bool   `json:"disableEPSV"`        // 被动模式下使用 PASV 代替 EPSV，兼容不支持 EPSV 的服务器
+	Path               string `json:"path" v:"required#Path不能为空"`
+	Domain             string `json:"domain" v:"required#Domain不能为空"`
+	Timeout            int64  `json:"timeout"`  // 连接超时时间，单位秒
+	PoolSize           int    `json:"poolSize"` // 最大连接数
+}
+
+// FtpAdapter FTP连接不支持并发，每个操作从连接池获取独立的连接，数据传输只使用被动模式
+type FtpAdapter struct {
+	config  *ConfigFtp
+	addr    string
+	options []ftp.DialOption
+	slots   chan struct{}        // 限制同时打开的连接数
+	idle    chan *ftp.ServerConn // 空闲连接
+}
+
+func NewAdapterFtp(i interface{}) (Adapter, error) {
+	cfg := (*ConfigFtp)(nil)
+	if err := gconv.Scan(i, &cfg); err != nil {
+		return nil, err
+	}
+	if verr := gvalid.New().Data(&cfg).Run(context.Background()); verr != nil {
+		if err := verr.FirstError(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 4
+	}
+	if cfg.Username == "" {
+		cfg.Username, cfg.Password = "anonymous", "anonymous"
+	}
+	cfg.Path = path.Clean("/" + strings.TrimSpace(cfg.Path))
+
+	options := []ftp.DialOption{
+		ftp.DialWithTimeout(time.Duration(cfg.Timeout) * time.Second),
+		ftp.DialWithDisabledEPSV(cfg.DisableEPSV),
+	}
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	switch cfg.TLS {
+	case "":
+		if cfg.Port <= 0 {
+			cfg.Port = 21
+		}
+	case FtpTLSExplicit:
+		if cfg.Port <= 0 {
+			cfg.Port = 21
+		}
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	case FtpTLSImplicit:
+		if cfg.Port <= 0 {
+			cfg.Port = 990
+		}
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	default:
+		return nil, gerror.Newf("TLS模式[%s]不支持", cfg.TLS)
+	}
+	return &FtpAdapter{
+		config:  cfg,
+		addr:    net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		options: options,
+		slots:   make(chan struct{}, cfg.PoolSize),
+		idle:    make(chan *ftp.ServerConn, cfg.PoolSize),
+	}, nil
+}
+
+// acquire 从连接池获取连接，连接数达到上限时等待其他操作释放
+func (f *FtpAdapter) acquire(ctx context.Context) (conn *ftp.ServerConn, err error) {
+	select {
+	case f.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case conn = <-f.idle:
+		// 空闲连接可能已经被服务器因超时断开，检查失败时重新建立连接
+		if conn.NoOp() == nil {
+			return conn, nil
+		}
+		conn.Quit()
+	default:
+	}
+	options := append([]ftp.DialOption{ftp.DialWithContext(ctx)}, f.options...)
+	if conn, err = ftp.Dial(f.addr, options...); err != nil {
+		<-f.slots
+		return
+	}
+	if err = conn.Login(f.config.Username, f.config.Password); err != nil {
+		conn.Quit()
+		<-f.slots
+		return nil, wrapError(ErrPermission, err)
+	}
+	return conn, nil
+}
+
+// release 把连接放回连接池，操作出错且连接已经断开时直接关闭
+func (f *FtpAdapter) release(conn *ftp.ServerConn, err error) {
+	defer func() {
+		<-f.slots
+	}()
+	var protoErr *textproto.Error
+	if err != nil && !errors.As(err, &protoErr) && conn.NoOp() != nil {
+		conn.Quit()
+		return
+	}
+	select {
+	case f.idle <- conn:
+	default:
+		conn.Quit()
+	}
+}
+
+// do 获取连接执行操作，完成后释放连接
+func (f *FtpAdapter) do(ctx context.Context, fn func(conn *ftp.ServerConn) error) (err error) {
+	conn, err := f.acquire(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.release(conn, err)
+	}()
+	return fn(conn)
+}
+
+// Close 关闭全部空闲连接
+func (f *FtpAdapter) Close() (err error) {
+	for {
+		select {
+		case conn := <-f.idle:
+			conn.Quit()
+		default:
+			return
+		}
+	}
+}
+
+// fullPath 获取文件在服务器上的完整路径，不允许跳出存储目录
+func (f *FtpAdapter) fullPath(object string) (string, error) {
+	filePath := path.Join(f.config.Path, object)
+	if filePath != f.config.Path && !strings.HasPrefix(filePath, strings.TrimSuffix(f.config.Path, "/")+"/") {
+		return "", invalidPathError(object)
+	}
+	return filePath, nil
+}
+
+// mkdirAll 逐级创建目录，FTP没有递归创建目录的命令，已存在的目录会返回错误，直接忽略
+func (f *FtpAdapter) mkdirAll(conn *ftp.ServerConn, dir string) {
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	for i := range parts {
+		conn.MakeDir("/" + strings.Join(parts[:i+1], "/"))
+	}
+}
+
+func (f *FtpAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = f.GetInfo(ctx, object)
+	return
+}
+
+func (f *FtpAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	savePath, err := f.fullPath(path)
+	if err != nil {
+		return
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	return f.do(ctx, func(conn *ftp.ServerConn) error {
+		f.mkdirAll(conn, gfile.Dir(savePath))
+		return ftpError(conn.Stor(savePath, reader))
+	})
+}
+
+func (f *FtpAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	return f.do(ctx, func(conn *ftp.ServerConn) error {
+		var errs []error
+		for _, object := range objects {
+			filePath, err := f.fullPath(object)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err = ftpError(conn.Delete(filePath)); err != nil && !errors.Is(err, ErrNotExist) {
+				errs = append(errs, gerror.Wrapf(err, "删除文件[%s]失败", object))
+			}
+		}
+		return joinErrors(errs...)
+	})
+}
+
+func (f *FtpAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	link = gfile.Join(f.config.Domain, object)
+	return
+}
+
+func (f *FtpAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return f.DownloadRange(ctx, object, 0, 0)
+}
+
+func (f *FtpAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	filePath, err := f.fullPath(object)
+	if err != nil {
+		return
+	}
+	conn, err := f.acquire(ctx)
+	if err != nil {
+		return
+	}
+	resp, err := conn.RetrFrom(filePath, uint64(offset))
+	if err != nil {
+		err = ftpError(err)
+		f.release(conn, err)
+		return
+	}
+	// 数据传输结束前连接不能被其他操作使用，关闭 body 时才释放连接
+	return limitBody(&ftpBody{Response: resp, release: func(err error) {
+		f.release(conn, err)
+	}}, length), nil
+}
+
+func (f *FtpAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	filePath, err := f.fullPath(object)
+	if err != nil {
+		return
+	}
+	err = f.do(ctx, func(conn *ftp.ServerConn) (err error) {
+		entry, err := f.getEntry(conn, filePath)
+		if err != nil {
+			if err = ftpError(err); errors.Is(err, ErrNotExist) {
+				return notExistError(object)
+			}
+			return
+		}
+		info = ftpFile(objectRel(object), entry)
+		return
+	})
+	return
+}
+
+// getEntry 获取文件信息，服务器不支持 MLST 时，从上级目录的列表中查找
+func (f *FtpAdapter) getEntry(conn *ftp.ServerConn, filePath string) (*ftp.Entry, error) {
+	entry, err := conn.GetEntry(filePath)
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code == ftp.StatusNotImplemented {
+		return f.findEntry(conn, filePath)
+	}
+	return entry, err
+}
+
+// findEntry 列出上级目录查找文件
+func (f *FtpAdapter) findEntry(conn *ftp.ServerConn, filePath string) (*ftp.Entry, error) {
+	if filePath == "/" {
+		return &ftp.Entry{Name: "/", Type: ftp.EntryTypeFolder}, nil
+	}
+	entries, err := conn.List(path.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Name == path.Base(filePath) {
+			return entry, nil
+		}
+	}
+	return nil, ErrNotExist
+}
+
+func (f *FtpAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	srcPath, err := f.fullPath(src)
+	if err != nil {
+		return
+	}
+	dstPath, err := f.fullPath(dst)
+	if err != nil {
+		return
+	}
+	if srcPath == dstPath {
+		return f.IsExist(ctx, src)
+	}
+	return f.do(ctx, func(conn *ftp.ServerConn) (err error) {
+		if _, err = f.getEntry(conn, srcPath); err != nil {
+			if err = ftpError(err); errors.Is(err, ErrNotExist) {
+				return notExistError(src)
+			}
+			return
+		}
+		f.mkdirAll(conn, gfile.Dir(dstPath))
+		if _, err = f.getEntry(conn, dstPath); err != nil {
+			if err = ftpError(err); !errors.Is(err, ErrNotExist) {
+				return
+			}
+			return ftpError(conn.Rename(srcPath, dstPath))
+		}
+		// 部分服务器不允许覆盖已存在的文件，目标文件已存在时先改名备份，
+		// 移动成功后再删除，失败时恢复，避免移动失败导致目标文件丢失
+		backup := path.Join(path.Dir(dstPath), ".filesys-move-"+guid.S())
+		if err = conn.Rename(dstPath, backup); err != nil {
+			return ftpError(err)
+		}
+		if err = conn.Rename(srcPath, dstPath); err != nil {
+			conn.Rename(backup, dstPath)
+			return ftpError(err)
+		}
+		conn.Delete(backup)
+		return nil
+	})
+}
+
+// Copy FTP没有复制命令，在同一个连接上先把源文件下载到临时文件再上传，
+// 避免下载和上传同时占用两个连接，连接池耗尽时互相等待
+func (f *FtpAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	srcPath, err := f.fullPath(src)
+	if err != nil {
+		return
+	}
+	dstPath, err := f.fullPath(dst)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp("", "filesys-ftp-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	return f.do(ctx, func(conn *ftp.ServerConn) (err error) {
+		resp, err := conn.Retr(srcPath)
+		if err != nil {
+			if err = ftpError(err); errors.Is(err, ErrNotExist) {
+				return notExistError(src)
+			}
+			return
+		}
+		_, err = io.Copy(tmp, resp)
+		if closeErr := resp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return ftpError(err)
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		f.mkdirAll(conn, gfile.Dir(dstPath))
+		return ftpError(conn.Stor(dstPath, tmp))
+	})
+}
+
+func (f *FtpAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, f, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (f *FtpAdapter) pathOrder() {}
+
+// ListPage 按目录层级顺序遍历，token 为上一页最后一个文件的相对路径
+func (f *FtpAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	result = &ListResult{}
+	// 只需要遍历前缀所在的目录
+	dir := path.Dir(prefix)
+	if dir == "." {
+		dir = ""
+	}
+	err = f.do(ctx, func(conn *ftp.ServerConn) error {
+		return f.walk(ctx, conn, dir, func(rel string, entry *ftp.Entry) (bool, error) {
+			if entry.Type == ftp.EntryTypeFolder {
+				// 跳过在之前的分页中已经遍历完的目录
+				return token == "" || comparePath(rel, token) >= 0 || strings.HasPrefix(token, rel+"/"), nil
+			}
+			if !strings.HasPrefix(rel, prefix) || (token != "" && comparePath(rel, token) <= 0) {
+				return false, nil
+			}
+			if len(result.Files) >= limit {
+				result.NextToken = result.Files[len(result.Files)-1].Name
+				return false, errStopWalk
+			}
+			result.Files = append(result.Files, ftpFile(rel, entry))
+			return false, nil
+		})
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return
+}
+
+func (f *FtpAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	dir := strings.TrimSuffix(dirPrefix(prefix), "/")
+	dirPath, err := f.fullPath(dir)
+	if err != nil {
+		return
+	}
+	err = f.do(ctx, func(conn *ftp.ServerConn) error {
+		entries, err := f.readDir(conn, dirPath)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			files = append(files, ftpFile(path.Join(dir, entry.Name), entry))
+		}
+		return nil
+	})
+	return
+}
+
+// readDir 列出目录下的文件和子目录，服务器支持时使用 MLSD，目录不存在时返回空
+func (f *FtpAdapter) readDir(conn *ftp.ServerConn, dirPath string) (entries []*ftp.Entry, err error) {
+	list, err := conn.List(dirPath)
+	if err != nil {
+		if err = ftpError(err); errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return
+	}
+	for _, entry := range list {
+		if entry.Name == "." || entry.Name == ".." || (entry.Type != ftp.EntryTypeFile && entry.Type != ftp.EntryTypeFolder) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return
+}
+
+// walk 按文件名顺序深度优先遍历目录，与 filepath.WalkDir 的顺序一致，fn 返回 true 时进入子目录
+func (f *FtpAdapter) walk(ctx context.Context, conn *ftp.ServerConn, dir string, fn func(rel string, entry *ftp.Entry) (bool, error)) (err error) {
+	dirPath, err := f.fullPath(dir)
+	if err != nil {
+		return
+	}
+	entries, err := f.readDir(conn, dirPath)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		rel := path.Join(dir, entry.Name)
+		enter, err := fn(rel, entry)
+		if err != nil {
+			return err
+		}
+		if enter && entry.Type == ftp.EntryTypeFolder {
+			if err = f.walk(ctx, conn, rel, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ftpBody 读取完成后关闭数据连接并释放控制连接
+type ftpBody struct {
+	*ftp.Response
+	eof     bool
+	once    sync.Once
+	release func(err error)
+}
+
+func (b *ftpBody) Read(p []byte) (n int, err error) {
+	n, err = b.Response.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return
+}
+
+func (b *ftpBody) Close() (err error) {
+	b.once.Do(func() {
+		err = b.Response.Close()
+		// 分段下载读取到需要的长度后提前关闭，服务器会返回传输中止，控制连接仍然可用
+		var protoErr *textproto.Error
+		if !b.eof && errors.As(err, &protoErr) &&
+			(protoErr.Code == ftp.StatusTransfertAborted || protoErr.Code == ftp.StatusActionAborted) {
+			err = nil
+		}
+		b.release(err)
+	})
+	return
+}
+
+// ftpFile 把FTP的文件信息转换为 File
+func ftpFile(name string, entry *ftp.Entry) *File {
+	file := &File{
+		ModTime: entry.Time,
+		Name:    name,
+		Size:    int64(entry.Size),
+		IsDir:   entry.Type == ftp.EntryTypeFolder,
+		Header:  map[string]string{},
+	}
+	if file.IsDir {
+		file.Size = 0
+	}
+	return file
+}
+
+// ftpError 根据FTP响应码对错误进行归类
+func ftpError(err error) error {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return err
+	}
+	switch protoErr.Code {
+	case ftp.StatusFileUnavailable:
+		return wrapError(ErrNotExist, err)
+	case ftp.StatusNotLoggedIn:
+		return wrapError(ErrPermission, err)
+	case ftp.StatusBadFileName:
+		return wrapError(ErrInvalidPath, err)
+	}
+	if protoErr.Code >= 400 && protoErr.Code < 500 {
+		// 4xx 为临时性的失败，例如连接数过多、文件被占用
+		return temporaryError(err)
+	}
+	return err
+}
@@ -0,0 +1,539 @@
+package filesys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ftpTestServer 进程内的FTP服务器，只实现适配器用到的命令
+type ftpTestServer struct {
+	listener    net.Listener
+	mlst        bool // 是否支持 MLST/MLSD
+	noOverwrite bool // 重命名时不允许覆盖已存在的文件
+
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	busy  map[string]bool // 被占用的文件，不能重命名和删除
+	conns map[net.Conn]bool
+}
+
+func newFtpTestServer(t *testing.T, mlst, noOverwrite bool) *ftpTestServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ftpTestServer{
+		listener:    listener,
+		mlst:        mlst,
+		noOverwrite: noOverwrite,
+		files:       map[string][]byte{},
+		dirs:        map[string]bool{"/": true},
+		busy:        map[string]bool{},
+		conns:       map[net.Conn]bool{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = true
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConns()
+	})
+	return s
+}
+
+// adapter 创建连接到该服务器的适配器
+func (s *ftpTestServer) adapter(t *testing.T, poolSize int) *FtpAdapter {
+	t.Helper()
+	addr := s.listener.Addr().(*net.TCPAddr)
+	adapter, err := NewAdapterFtp(ConfigFtp{
+		Host:     addr.IP.String(),
+		Port:     addr.Port,
+		Path:     "/data",
+		Domain:   "http://localhost",
+		PoolSize: poolSize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		adapter.(*FtpAdapter).Close()
+	})
+	return adapter.(*FtpAdapter)
+}
+
+// dropConns 断开全部控制连接，模拟服务器关闭超时的空闲连接
+func (s *ftpTestServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func (s *ftpTestServer) file(name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	return data, ok
+}
+
+func (s *ftpTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var (
+		data     net.Listener
+		offset   int64
+		renaming string
+	)
+	// openData 接受被动模式的数据连接
+	openData := func() (net.Conn, bool) {
+		if data == nil {
+			reply("425 Use EPSV first")
+			return nil, false
+		}
+		defer func() {
+			data.Close()
+			data = nil
+		}()
+		dataConn, err := data.Accept()
+		if err != nil {
+			reply("425 Can't open data connection")
+			return nil, false
+		}
+		return dataConn, true
+	}
+
+	reply("220 ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg := strings.TrimSpace(line), ""
+		if i := strings.IndexByte(cmd, ' '); i >= 0 {
+			cmd, arg = cmd[:i], cmd[i+1:]
+		}
+		switch strings.ToUpper(cmd) {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			reply("230 logged in")
+		case "FEAT":
+			if s.mlst {
+				reply("211-Features:\r\n MLST type*;size*;modify*;\r\n UTF8\r\n211 End")
+			} else {
+				reply("211-Features:\r\n UTF8\r\n211 End")
+			}
+		case "TYPE", "OPTS", "NOOP":
+			reply("200 ok")
+		case "EPSV":
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "REST":
+			offset, _ = strconv.ParseInt(arg, 10, 64)
+			reply("350 restarting")
+		case "RETR":
+			content, ok := s.file(arg)
+			if !ok {
+				reply("550 not found")
+				continue
+			}
+			dataConn, ok := openData()
+			if !ok {
+				continue
+			}
+			reply("150 sending")
+			if offset > int64(len(content)) {
+				offset = int64(len(content))
+			}
+			_, err = dataConn.Write(content[offset:])
+			dataConn.Close()
+			offset = 0
+			if err != nil {
+				reply("426 transfer aborted")
+			} else {
+				reply("226 transfer complete")
+			}
+		case "STOR":
+			dataConn, ok := openData()
+			if !ok {
+				continue
+			}
+			reply("150 receiving")
+			content, _ := io.ReadAll(dataConn)
+			dataConn.Close()
+			s.mu.Lock()
+			if s.dirs[path.Dir(arg)] {
+				s.files[arg] = content
+				s.mu.Unlock()
+				reply("226 transfer complete")
+			} else {
+				s.mu.Unlock()
+				reply("553 no such directory")
+			}
+		case "DELE":
+			s.mu.Lock()
+			_, ok := s.files[arg]
+			busy := s.busy[arg]
+			if !busy {
+				delete(s.files, arg)
+			}
+			s.mu.Unlock()
+			switch {
+			case busy:
+				reply("450 file busy")
+			case ok:
+				reply("250 deleted")
+			default:
+				reply("550 not found")
+			}
+		case "MKD":
+			s.mu.Lock()
+			exists := s.dirs[arg]
+			s.dirs[arg] = true
+			s.mu.Unlock()
+			if exists {
+				reply("550 already exists")
+			} else {
+				reply("257 \"%s\" created", arg)
+			}
+		case "RNFR":
+			renaming = arg
+			reply("350 ready for RNTO")
+		case "RNTO":
+			s.mu.Lock()
+			content, ok := s.files[renaming]
+			_, exists := s.files[arg]
+			switch {
+			case !ok:
+				reply("550 not found")
+			case s.busy[renaming]:
+				reply("450 file busy")
+			case exists && s.noOverwrite:
+				reply("550 file exists")
+			default:
+				delete(s.files, renaming)
+				s.files[arg] = content
+				reply("250 renamed")
+			}
+			s.mu.Unlock()
+		case "MLST":
+			if !s.mlst {
+				reply("502 not implemented")
+				continue
+			}
+			entry, ok := s.entry(arg, true)
+			if !ok {
+				reply("550 not found")
+				continue
+			}
+			reply("250-Listing %s\r\n %s\r\n250 End", arg, entry)
+		case "MLSD", "LIST":
+			entries := s.list(arg, cmd == "MLSD")
+			dataConn, ok := openData()
+			if !ok {
+				continue
+			}
+			reply("150 listing")
+			for _, entry := range entries {
+				fmt.Fprintf(dataConn, "%s\r\n", entry)
+			}
+			dataConn.Close()
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// entry 生成文件的列表行，mlst 为 true 时使用 RFC 3659 格式，否则使用 ls 格式
+func (s *ftpTestServer) entry(name string, mlst bool) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, isFile := s.files[name]
+	if !isFile && !s.dirs[name] {
+		return "", false
+	}
+	base := path.Base(name)
+	if mlst {
+		if isFile {
+			return fmt.Sprintf("type=file;size=%d;modify=20230102150405; %s", len(content), base), true
+		}
+		return fmt.Sprintf("type=dir;modify=20230102150405; %s", base), true
+	}
+	if isFile {
+		return fmt.Sprintf("-rw-r--r-- 1 owner group %d Jan 02 2023 %s", len(content), base), true
+	}
+	return fmt.Sprintf("drwxr-xr-x 1 owner group 0 Jan 02 2023 %s", base), true
+}
+
+// list 列出目录下的文件和子目录
+func (s *ftpTestServer) list(dir string, mlst bool) (entries []string) {
+	s.mu.Lock()
+	var names []string
+	for name := range s.files {
+		if path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	for name := range s.dirs {
+		if name != dir && path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		entry, _ := s.entry(name, mlst)
+		entries = append(entries, entry)
+	}
+	return
+}
+
+func TestFtpAdapterPoolExhaustion(t *testing.T) {
+	server := newFtpTestServer(t, true, false)
+	adapter := server.adapter(t, 1)
+	ctx := context.Background()
+	if err := adapter.Upload(ctx, "a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// 下载未关闭时唯一的连接被占用，其他操作需要等待
+	body, err := adapter.Download(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = adapter.GetInfo(timeoutCtx, "a.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetInfo with exhausted pool: got %v, want deadline exceeded", err)
+	}
+	if err = body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = adapter.GetInfo(ctx, "a.txt"); err != nil {
+		t.Fatalf("GetInfo after release: %v", err)
+	}
+
+	// 复制只占用一个连接，连接池大小为1时不会互相等待
+	copyCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err = copyObject(copyCtx, adapter, "a.txt", "dir/b.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if content, _ := server.file("/data/dir/b.txt"); string(content) != "hello" {
+		t.Fatalf("Copy content = %q, want %q", content, "hello")
+	}
+}
+
+func TestFtpAdapterReconnectIdle(t *testing.T) {
+	server := newFtpTestServer(t, true, false)
+	adapter := server.adapter(t, 1)
+	ctx := context.Background()
+	if err := adapter.Upload(ctx, "a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+	// 服务器断开空闲连接后，下一次操作重新建立连接
+	server.dropConns()
+	if _, err := adapter.GetInfo(ctx, "a.txt"); err != nil {
+		t.Fatalf("GetInfo after idle connection dropped: %v", err)
+	}
+}
+
+func TestFtpAdapterMove(t *testing.T) {
+	for _, noOverwrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("noOverwrite=%v", noOverwrite), func(t *testing.T) {
+			server := newFtpTestServer(t, true, noOverwrite)
+			adapter := server.adapter(t, 2)
+			ctx := context.Background()
+			for name, content := range map[string]string{"src.txt": "src", "dst.txt": "dst"} {
+				if err := adapter.Upload(ctx, name, strings.NewReader(content), int64(len(content))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := adapter.Move(ctx, "src.txt", "dst.txt"); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+			if content, _ := server.file("/data/dst.txt"); string(content) != "src" {
+				t.Fatalf("dst content = %q, want %q", content, "src")
+			}
+			if _, ok := server.file("/data/src.txt"); ok {
+				t.Fatal("src still exists after Move")
+			}
+
+			// 源文件不存在时不能删除目标文件
+			if err := adapter.Move(ctx, "missing.txt", "dst.txt"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Move missing src: got %v, want ErrNotExist", err)
+			}
+			if content, _ := server.file("/data/dst.txt"); string(content) != "src" {
+				t.Fatalf("dst content after failed Move = %q, want %q", content, "src")
+			}
+
+			// 移动到自身时不删除文件
+			if err := adapter.Move(ctx, "dst.txt", "/dst.txt"); err != nil {
+				t.Fatalf("Move onto itself: %v", err)
+			}
+			if content, _ := server.file("/data/dst.txt"); string(content) != "src" {
+				t.Fatalf("dst content after Move onto itself = %q, want %q", content, "src")
+			}
+
+			// 重命名失败时恢复目标文件，不留下备份文件
+			if err := adapter.Upload(ctx, "busy.txt", strings.NewReader("busy"), 4); err != nil {
+				t.Fatal(err)
+			}
+			server.mu.Lock()
+			server.busy["/data/busy.txt"] = true
+			server.mu.Unlock()
+			if err := adapter.Move(ctx, "busy.txt", "dst.txt"); err == nil {
+				t.Fatal("Move busy file: want error")
+			}
+			if content, _ := server.file("/data/dst.txt"); string(content) != "src" {
+				t.Fatalf("dst content after failed rename = %q, want %q", content, "src")
+			}
+			if got := server.list("/data", true); len(got) != 2 {
+				t.Fatalf("files after failed rename = %v, want busy.txt and dst.txt", got)
+			}
+		})
+	}
+}
+
+func TestFtpAdapterDelete(t *testing.T) {
+	server := newFtpTestServer(t, true, false)
+	adapter := server.adapter(t, 1)
+	ctx := context.Background()
+	for _, name := range []string{"a.txt", "busy.txt", "c.txt"} {
+		if err := adapter.Upload(ctx, name, strings.NewReader(name), int64(len(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.mu.Lock()
+	server.busy["/data/busy.txt"] = true
+	server.mu.Unlock()
+
+	// 删除失败的文件不影响其他文件，错误合并返回，不存在的文件忽略
+	err := adapter.Delete(ctx, "a.txt", "busy.txt", "missing.txt", "../c.txt", "c.txt")
+	if err == nil || !strings.Contains(err.Error(), "busy.txt") || !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("Delete = %v, want the busy.txt error and ErrInvalidPath", err)
+	}
+	for name, want := range map[string]bool{"/data/a.txt": false, "/data/busy.txt": true, "/data/c.txt": false} {
+		if _, ok := server.file(name); ok != want {
+			t.Errorf("%s exists = %v, want %v", name, ok, want)
+		}
+	}
+}
+
+func TestFtpAdapterGetInfo(t *testing.T) {
+	for _, mlst := range []bool{true, false} {
+		t.Run(fmt.Sprintf("mlst=%v", mlst), func(t *testing.T) {
+			server := newFtpTestServer(t, mlst, false)
+			adapter := server.adapter(t, 1)
+			ctx := context.Background()
+			if err := adapter.Upload(ctx, "dir/a.txt", strings.NewReader("hello"), 5); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				object string
+				size   int64
+				isDir  bool
+				err    error
+			}{
+				{object: "dir/a.txt", size: 5},
+				{object: "dir", isDir: true},
+				{object: "dir/missing.txt", err: ErrNotExist},
+			}
+			for _, tt := range tests {
+				info, err := adapter.GetInfo(ctx, tt.object)
+				if tt.err != nil {
+					if !errors.Is(err, tt.err) {
+						t.Errorf("GetInfo(%q): got %v, want %v", tt.object, err, tt.err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("GetInfo(%q): %v", tt.object, err)
+					continue
+				}
+				if info.Size != tt.size || info.IsDir != tt.isDir {
+					t.Errorf("GetInfo(%q) = size %d isDir %v, want size %d isDir %v", tt.object, info.Size, info.IsDir, tt.size, tt.isDir)
+				}
+			}
+		})
+	}
+}
+
+func TestFtpAdapterDownloadRange(t *testing.T) {
+	server := newFtpTestServer(t, true, false)
+	adapter := server.adapter(t, 1)
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789"), 1<<16)
+	if err := adapter.Upload(ctx, "big.bin", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset, length int64
+	}{
+		{offset: 0, length: 10},
+		{offset: 15, length: 5},
+		{offset: int64(len(content)) - 3, length: 0},
+	}
+	for _, tt := range tests {
+		body, err := adapter.DownloadRange(ctx, "big.bin", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("DownloadRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 读取到需要的长度后提前关闭，连接需要能够继续使用
+		if err = body.Close(); err != nil {
+			t.Fatalf("Close after DownloadRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		want := content[tt.offset:]
+		if tt.length > 0 {
+			want = want[:tt.length]
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("DownloadRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, want)
+		}
+		if _, err = adapter.GetInfo(ctx, "big.bin"); err != nil {
+			t.Fatalf("GetInfo after DownloadRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+	}
+}
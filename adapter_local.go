@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"context"
 	"errors"
-	"fmt"
 	"github.com/gogf/gf/v2/os/gfile"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -47,42 +49,57 @@ func NewAdapterLocal(i interface{}) (Adapter, error) {
 	return c, nil
 }
 
+// fullPath 获取文件在本地的完整路径，不允许跳出存储目录
+func (c *LocalAdapter) fullPath(object string) (string, error) {
+	filePath := filepath.Join(c.config.Path, object)
+	rel, err := filepath.Rel(c.config.Path, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", invalidPathError(object)
+	}
+	return filePath, nil
+}
+
 func (c *LocalAdapter) IsExist(ctx context.Context, object string) (err error) {
-	exist := gfile.Exists(fmt.Sprintf("%s/%s", c.config.Path, object))
-	if !exist {
-		return errors.New("not exist file")
+	filePath, err := c.fullPath(object)
+	if err != nil {
+		return
+	}
+	if !gfile.Exists(filePath) {
+		return notExistError(object)
 	}
 	return
 }
 
 func (c *LocalAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
-	savePath := gfile.Join(c.config.Path, path)
+	savePath, err := c.fullPath(path)
+	if err != nil {
+		return
+	}
 
 	file, err := gfile.Create(savePath)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 	gfile.Chmod(savePath, os.FileMode(0666))
 	bufWriter := bufio.NewWriter(file)
-	bufWriter.ReadFrom(reader)
-	bufWriter.Flush()
-	file.Close()
-	return
+	if _, err = bufWriter.ReadFrom(reader); err != nil {
+		return
+	}
+	return bufWriter.Flush()
 }
 
 func (c *LocalAdapter) Delete(ctx context.Context, objects ...string) (err error) {
-	var errs []string
+	var errs []error
 	for _, object := range objects {
-		filePath := gfile.Join(c.config.Path, object)
-		err = gfile.Remove(filePath)
-		if err != nil {
-			errs = append(errs, err.Error())
+		filePath, errPath := c.fullPath(object)
+		if errPath != nil {
+			errs = append(errs, errPath)
+			continue
 		}
+		errs = append(errs, gfile.Remove(filePath))
 	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
-	return
+	return joinErrors(errs...)
 }
 
 func (c *LocalAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -91,18 +108,37 @@ func (c *LocalAdapter) GetSignURL(ctx context.Context, object string, expire ...
 }
 
 func (c *LocalAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
-	filePath := gfile.Join(c.config.Path, object)
+	filePath, err := c.fullPath(object)
+	if err != nil {
+		return
+	}
 	body, err = gfile.Open(filePath)
 	return
 }
 
-func (c *LocalAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
-	filePath := gfile.Join(c.config.Path, object)
+func (c *LocalAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	filePath, err := c.fullPath(object)
+	if err != nil {
+		return
+	}
 	file, err := gfile.Open(filePath)
 	if err != nil {
 		return
 	}
-	fileInfo, err := file.Stat()
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return
+	}
+	body = limitBody(file, length)
+	return
+}
+
+func (c *LocalAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	filePath, err := c.fullPath(object)
+	if err != nil {
+		return
+	}
+	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +152,125 @@ func (c *LocalAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	return
 }
 
+func (c *LocalAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	srcPath, err := c.fullPath(src)
+	if err != nil {
+		return
+	}
+	dstPath, err := c.fullPath(dst)
+	if err != nil {
+		return
+	}
+	return gfile.CopyFile(srcPath, dstPath)
+}
+
+func (c *LocalAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	srcPath, err := c.fullPath(src)
+	if err != nil {
+		return
+	}
+	dstPath, err := c.fullPath(dst)
+	if err != nil {
+		return
+	}
+	if err = gfile.Mkdir(gfile.Dir(dstPath)); err != nil {
+		return
+	}
+	return gfile.Rename(srcPath, dstPath)
+}
+
 func (c *LocalAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, c, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (c *LocalAdapter) pathOrder() {}
+
+// ListPage 按目录层级顺序遍历，token 为上一页最后一个文件的相对路径
+func (c *LocalAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	// 只需要遍历前缀所在的目录
+	root, err := c.fullPath(path.Dir(prefix))
+	if err != nil {
+		return
+	}
+	result = &ListResult{}
+	if !gfile.Exists(root) {
+		return
+	}
+	err = filepath.WalkDir(root, func(filePath string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil {
+			return errWalk
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.config.Path, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			// 跳过在之前的分页中已经遍历完的目录
+			if token != "" && rel != "." && comparePath(rel, token) < 0 && !strings.HasPrefix(token, rel+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(rel, prefix) || (token != "" && comparePath(rel, token) <= 0) {
+			return nil
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			return errStopWalk
+		}
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result.Files = append(result.Files, &File{
+			ModTime: fileInfo.ModTime(),
+			Name:    rel,
+			Size:    fileInfo.Size(),
+			IsDir:   false,
+			Header:  map[string]string{},
+		})
+		return nil
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return
+}
+
+func (c *LocalAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	dir := strings.TrimSuffix(dirPrefix(prefix), "/")
+	dirPath, err := c.fullPath(dir)
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return
+	}
+	for _, entry := range entries {
+		var fileInfo fs.FileInfo
+		if fileInfo, err = entry.Info(); err != nil {
+			return nil, err
+		}
+		files = append(files, &File{
+			ModTime: fileInfo.ModTime(),
+			Name:    path.Join(dir, entry.Name()),
+			Size:    fileInfo.Size(),
+			IsDir:   entry.IsDir(),
+			Header:  map[string]string{},
+		})
+	}
 	return
 }
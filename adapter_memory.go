@@ -0,0 +1,367 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ConfigMemory struct {
+	Domain string `json:"domain"`
+	Expire int64  `json:"expire"`
+}
+
+// MemoryObject 内存中保存的文件
+type MemoryObject struct {
+	Data    []byte
+	Header  map[string]string
+	ModTime time.Time
+}
+
+// MemoryAdapter 把文件保存在内存中，用于单元测试，并发安全
+type MemoryAdapter struct {
+	config  *ConfigMemory
+	mu      sync.RWMutex
+	objects map[string]*MemoryObject
+	uploads map[string]*memoryUpload
+}
+
+// memoryUpload 未完成的分片上传
+type memoryUpload struct {
+	object string
+	header map[string]string
+	parts  map[int][]byte
+}
+
+func NewAdapterMemory(i interface{}) (Adapter, error) {
+	cfg := &ConfigMemory{}
+	if i != nil {
+		if err := gconv.Scan(i, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Domain == "" {
+		cfg.Domain = "http://memory.local"
+	}
+	cfg.Domain = strings.TrimRight(cfg.Domain, "/")
+	return &MemoryAdapter{
+		config:  cfg,
+		objects: make(map[string]*MemoryObject),
+		uploads: make(map[string]*memoryUpload),
+	}, nil
+}
+
+// Snapshot 复制当前全部文件，可以通过 Restore 恢复
+func (m *MemoryAdapter) Snapshot() map[string]*MemoryObject {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string]*MemoryObject, len(m.objects))
+	for key, obj := range m.objects {
+		snapshot[key] = obj.clone()
+	}
+	return snapshot
+}
+
+// Restore 用快照替换当前全部文件
+func (m *MemoryAdapter) Restore(snapshot map[string]*MemoryObject) {
+	objects := make(map[string]*MemoryObject, len(snapshot))
+	for key, obj := range snapshot {
+		objects[objectRel(key)] = obj.clone()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = objects
+	m.uploads = make(map[string]*memoryUpload)
+}
+
+// Reset 清空全部文件和未完成的分片上传
+func (m *MemoryAdapter) Reset() {
+	m.Restore(nil)
+}
+
+func (m *MemoryAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = m.get(object)
+	return
+}
+
+func (m *MemoryAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return
+	}
+	m.put(path, data, mergeHeaders(headers...))
+	return
+}
+
+func (m *MemoryAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, object := range objects {
+		delete(m.objects, objectRel(object))
+	}
+	return
+}
+
+// GetSignURL 生成假的签名链接，只用于测试
+func (m *MemoryAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	exp := m.config.Expire
+	if len(expire) > 0 {
+		exp = expire[0]
+	}
+	return m.signURL(object, exp), nil
+}
+
+// GetUploadSignURL 生成假的上传链接，只用于测试
+func (m *MemoryAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	exp := uploadExpire(expire, m.config.Expire)
+	return newUploadSign(m.signURL(object, exp), exp, opts), nil
+}
+
+func (m *MemoryAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return m.DownloadRange(ctx, object, 0, 0)
+}
+
+func (m *MemoryAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	obj, err := m.get(object)
+	if err != nil {
+		return
+	}
+	data := obj.Data
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[offset:]
+	if length > 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MemoryAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	obj, err := m.get(object)
+	if err != nil {
+		return
+	}
+	return obj.file(objectRel(object)), nil
+}
+
+func (m *MemoryAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	obj, err := m.get(src)
+	if err != nil {
+		return
+	}
+	m.put(dst, obj.Data, obj.Header)
+	return
+}
+
+func (m *MemoryAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[objectRel(src)]
+	if !ok {
+		return notExistError(src)
+	}
+	delete(m.objects, objectRel(src))
+	m.objects[objectRel(dst)] = obj
+	return
+}
+
+func (m *MemoryAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	uploadID = guid.S()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads[uploadID] = &memoryUpload{
+		object: objectRel(object),
+		header: mergeHeaders(headers),
+		parts:  make(map[int][]byte),
+	}
+	return
+}
+
+func (m *MemoryAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, size))
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.upload(object, uploadID)
+	if err != nil {
+		return
+	}
+	upload.parts[number] = data
+	return &Part{Number: number, ETag: MD5Crypt(string(data)), Size: int64(len(data))}, nil
+}
+
+func (m *MemoryAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	m.mu.Lock()
+	upload, err := m.upload(object, uploadID)
+	if err != nil {
+		m.mu.Unlock()
+		return
+	}
+	var buf bytes.Buffer
+	for _, part := range parts {
+		data, ok := upload.parts[part.Number]
+		if !ok {
+			m.mu.Unlock()
+			return gerror.Newf("分片[%d]不存在", part.Number)
+		}
+		buf.Write(data)
+	}
+	delete(m.uploads, uploadID)
+	m.mu.Unlock()
+
+	m.put(object, buf.Bytes(), upload.header)
+	return
+}
+
+func (m *MemoryAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err = m.upload(object, uploadID); err != nil {
+		return
+	}
+	delete(m.uploads, uploadID)
+	return
+}
+
+func (m *MemoryAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, m, prefix)
+}
+
+func (m *MemoryAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return m.list(ctx, prefix, "", token, limit)
+}
+
+func (m *MemoryAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, m.list)
+}
+
+// list 按文件名顺序列出文件，指定分隔符时把下一级目录合并为一项，与对象存储的行为一致
+func (m *MemoryAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
+	prefix = objectRel(prefix)
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.objects))
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) && key > token {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// 上一页以合并的子目录结束时，跳过该目录下的文件，以分隔符结尾的占位对象不是合并的子目录
+	tokenDir := delimiter != "" && strings.HasPrefix(token, prefix) && strings.Contains(token[len(prefix):], delimiter)
+
+	result = &ListResult{}
+	lastDir, lastToken := "", ""
+	for _, key := range keys {
+		if tokenDir && strings.HasPrefix(key, token) {
+			continue
+		}
+		if lastDir != "" && strings.HasPrefix(key, lastDir) {
+			continue
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = lastToken
+			break
+		}
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				lastDir = key[:len(prefix)+idx+len(delimiter)]
+				lastToken = lastDir
+				result.Files = append(result.Files, dirFile(lastDir))
+				continue
+			}
+		}
+		lastToken = key
+		result.Files = append(result.Files, m.objects[key].file(key))
+	}
+	m.mu.RUnlock()
+	return
+}
+
+// get 获取文件，文件不存在时返回 ErrNotExist
+func (m *MemoryAdapter) get(object string) (*MemoryObject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[objectRel(object)]
+	if !ok {
+		return nil, notExistError(object)
+	}
+	return obj, nil
+}
+
+// put 保存文件，保存的是数据的副本
+func (m *MemoryAdapter) put(object string, data []byte, header map[string]string) {
+	obj := (&MemoryObject{Data: data, Header: header}).clone()
+	obj.ModTime = time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[objectRel(object)] = obj
+}
+
+// upload 获取未完成的分片上传，调用方需要持有锁
+func (m *MemoryAdapter) upload(object, uploadID string) (*memoryUpload, error) {
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.object != objectRel(object) {
+		return nil, &storeError{kind: ErrNotExist, err: gerror.Newf("分片上传[%s]不存在", uploadID)}
+	}
+	return upload, nil
+}
+
+// signURL 生成假的签名链接，expire 小于等于0时返回公开链接
+func (m *MemoryAdapter) signURL(object string, expire int64) string {
+	link := m.config.Domain + objectAbs(object)
+	if expire <= 0 {
+		return link
+	}
+	deadline := time.Now().Unix() + expire
+	return fmt.Sprintf("%s?expires=%d&signature=%s", link, deadline, MD5Crypt(fmt.Sprintf("%s&%d", objectAbs(object), deadline)))
+}
+
+// clone 深拷贝文件，避免调用方修改内存中的数据
+func (o *MemoryObject) clone() *MemoryObject {
+	header := make(map[string]string, len(o.Header))
+	for k, v := range o.Header {
+		header[k] = v
+	}
+	return &MemoryObject{
+		Data:    append([]byte(nil), o.Data...),
+		Header:  header,
+		ModTime: o.ModTime,
+	}
+}
+
+// file 生成文件信息
+func (o *MemoryObject) file(name string) *File {
+	header := make(map[string]string, len(o.Header))
+	for k, v := range o.Header {
+		header[k] = v
+	}
+	return &File{
+		ModTime: o.ModTime,
+		Name:    name,
+		Size:    int64(len(o.Data)),
+		IsDir:   isDirKey(name),
+		Header:  header,
+	}
+}
@@ -0,0 +1,252 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newMemoryAdapter 创建内存适配器并上传 files，key 为文件名，value 为内容
+func newMemoryAdapter(t *testing.T, files map[string]string) *MemoryAdapter {
+	t.Helper()
+	adapter, err := NewAdapterMemory(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := adapter.(*MemoryAdapter)
+	for name, content := range files {
+		if err = memory.Upload(context.Background(), name, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return memory
+}
+
+// readObject 读取文件内容
+func readObject(t *testing.T, adapter Adapter, object string) string {
+	t.Helper()
+	body, err := adapter.Download(context.Background(), object)
+	if err != nil {
+		t.Fatalf("Download(%s): %v", object, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("Download(%s): %v", object, err)
+	}
+	return string(data)
+}
+
+// fileNames 文件名列表，目录以 "/" 结尾
+func fileNames(files []*File) []string {
+	var names []string
+	for _, file := range files {
+		name := file.Name
+		if file.IsDir && !strings.HasSuffix(name, "/") {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMemoryAdapterSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, map[string]string{"a.txt": "a", "dir/b.txt": "b"})
+	snapshot := memory.Snapshot()
+	if len(snapshot) != 2 || string(snapshot["a.txt"].Data) != "a" {
+		t.Fatalf("Snapshot = %v", snapshot)
+	}
+	// 修改快照不影响适配器中的文件
+	snapshot["a.txt"].Data[0] = 'x'
+	if got := readObject(t, memory, "a.txt"); got != "a" {
+		t.Fatalf("a.txt = %q after modifying the snapshot", got)
+	}
+	snapshot["a.txt"].Data[0] = 'a'
+
+	uploadID, err := memory.InitMultipart(ctx, "c.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = memory.Upload(ctx, "a.txt", strings.NewReader("changed"), 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = memory.Delete(ctx, "dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	memory.Restore(snapshot)
+	if got := readObject(t, memory, "a.txt"); got != "a" {
+		t.Errorf("a.txt = %q after Restore, want %q", got, "a")
+	}
+	if got := readObject(t, memory, "/dir/b.txt"); got != "b" {
+		t.Errorf("dir/b.txt = %q after Restore, want %q", got, "b")
+	}
+	// Restore 会清空未完成的分片上传
+	if err = memory.AbortMultipart(ctx, "c.txt", uploadID); !errors.Is(err, ErrNotExist) {
+		t.Errorf("AbortMultipart after Restore: got %v, want ErrNotExist", err)
+	}
+
+	memory.Reset()
+	if files, err := memory.Lists(ctx, ""); err != nil || len(files) != 0 {
+		t.Errorf("Lists after Reset = %v, %v", fileNames(files), err)
+	}
+	if err = memory.IsExist(ctx, "a.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("IsExist after Reset: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestMemoryAdapterListDelimiter(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, map[string]string{
+		"a/1": "", "a/2": "", "a/b/3": "", "a0": "", "b": "", "c/": "", "c/d/e": "", "c/f": "", "g": "",
+	})
+	tests := []struct {
+		prefix    string
+		delimiter string
+		want      []string
+	}{
+		{"", "/", []string{"a/", "a0", "b", "c/", "g"}},
+		{"a/", "/", []string{"a/1", "a/2", "a/b/"}},
+		{"c/", "/", []string{"c/", "c/d/", "c/f"}},
+		{"a", "/", []string{"a/", "a0"}},
+		{"", "", []string{"a/1", "a/2", "a/b/3", "a0", "b", "c/", "c/d/e", "c/f", "g"}},
+		{"x", "/", nil},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 100} {
+			var (
+				got   []string
+				token string
+			)
+			for page := 0; ; page++ {
+				if page > 10 {
+					t.Fatalf("prefix %q limit %d: too many pages", tt.prefix, limit)
+				}
+				result, err := memory.list(ctx, tt.prefix, tt.delimiter, token, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(result.Files) > limit {
+					t.Fatalf("prefix %q limit %d: page has %d files", tt.prefix, limit, len(result.Files))
+				}
+				got = append(got, fileNames(result.Files)...)
+				if token = result.NextToken; token == "" {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("list(%q, %q) limit %d = %v, want %v", tt.prefix, tt.delimiter, limit, got, tt.want)
+			}
+		}
+	}
+
+	// ListDir 跳过目录自身的占位对象
+	files, err := memory.ListDir(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fileNames(files), []string{"c/d/", "c/f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir(c) = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryAdapterCompleteMultipart(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, nil)
+	uploadID, err := memory.InitMultipart(ctx, "big.bin", map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []*Part
+	for i, content := range []string{"one-", "two-", "three"} {
+		part, err := memory.UploadPart(ctx, "big.bin", uploadID, i+1, strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part)
+	}
+
+	tests := []struct {
+		name   string
+		object string
+		parts  []*Part
+		want   error
+	}{
+		{"missing part", "big.bin", append(parts[:2:2], &Part{Number: 4}), nil},
+		{"other object", "other.bin", parts, ErrNotExist},
+	}
+	for _, tt := range tests {
+		err = memory.CompleteMultipart(ctx, tt.object, uploadID, tt.parts)
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: CompleteMultipart = %v, want %v", tt.name, err, tt.want)
+		}
+		if err = memory.IsExist(ctx, "big.bin"); !errors.Is(err, ErrNotExist) {
+			t.Errorf("%s: file created after failed CompleteMultipart", tt.name)
+		}
+	}
+
+	// 失败后分片上传仍然保留，可以用正确的分片完成
+	if err = memory.CompleteMultipart(ctx, "big.bin", uploadID, []*Part{parts[0], parts[2]}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, memory, "big.bin"); got != "one-three" {
+		t.Errorf("big.bin = %q, want %q", got, "one-three")
+	}
+	info, err := memory.GetInfo(ctx, "big.bin")
+	if err != nil || info.Header["Content-Type"] != "application/octet-stream" {
+		t.Errorf("GetInfo = %v, %v", info, err)
+	}
+	if err = memory.CompleteMultipart(ctx, "big.bin", uploadID, parts); !errors.Is(err, ErrNotExist) {
+		t.Errorf("CompleteMultipart twice: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestMemoryAdapterConcurrent(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				name := fmt.Sprintf("dir/%d/%d", i, j%5)
+				content := fmt.Sprintf("%d-%d", i, j)
+				if err := memory.Upload(ctx, name, strings.NewReader(content), -1); err != nil {
+					t.Error(err)
+					return
+				}
+				body, err := memory.Download(ctx, name)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				data, _ := io.ReadAll(body)
+				body.Close()
+				// 只有当前协程写入该文件
+				if string(data) != content {
+					t.Errorf("%s = %q, want %q", name, data, content)
+					return
+				}
+				if _, err = memory.Lists(ctx, "dir/"); err != nil {
+					t.Error(err)
+					return
+				}
+				if j%10 == 0 {
+					memory.Snapshot()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	files, err := memory.Lists(ctx, "dir/")
+	if err != nil || len(files) != 8*5 {
+		t.Fatalf("Lists = %d files, %v", len(files), err)
+	}
+}
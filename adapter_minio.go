@@ -2,7 +2,6 @@ package filesys
 
 import (
 	"context"
-	"errors"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"io"
@@ -11,6 +10,7 @@ import (
 	"time"
 
 	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3signer"
 )
 
 type ConfigMinio struct {
@@ -51,33 +51,19 @@ func NewAdapterMinio(i interface{}) (Adapter, error) {
 	return m, err
 }
 
+// Bucket 存储桶名称
+func (m *MinIoAdapter) Bucket() string {
+	return m.config.Bucket
+}
+
 func (m *MinIoAdapter) IsExist(ctx context.Context, object string) (err error) {
 	_, err = m.GetInfo(ctx, object)
 	return
 }
 
 func (m *MinIoAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
-	opts := minio.PutObjectOptions{
-		UserMetadata: make(map[string]string),
-	}
-
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-disposition":
-				opts.ContentDisposition = v
-			case "content-encoding":
-				opts.ContentEncoding = v
-			case "content-type":
-				opts.ContentType = v
-			default:
-				opts.UserMetadata[k] = v
-			}
-		}
-	}
-
-	_, err = m.client.PutObject(m.config.Bucket, objectRel(path), reader, size, opts)
-	return
+	_, err = m.client.PutObject(m.config.Bucket, objectRel(path), reader, size, minioPutOptions(headers...))
+	return minioError(err)
 }
 
 func (m *MinIoAdapter) Delete(ctx context.Context, objects ...string) (err error) {
@@ -85,7 +71,7 @@ func (m *MinIoAdapter) Delete(ctx context.Context, objects ...string) (err error
 		return
 	}
 
-	var errs []string
+	var errs []error
 
 	objectsChan := make(chan string)
 	go func() {
@@ -95,14 +81,9 @@ func (m *MinIoAdapter) Delete(ctx context.Context, objects ...string) (err error
 		}
 	}()
 	for errRm := range m.client.RemoveObjects(m.config.Bucket, objectsChan) {
-		if errRm.Err != nil {
-			errs = append(errs, errRm.Err.Error())
-		}
+		errs = append(errs, minioError(errRm.Err))
 	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
-	return
+	return joinErrors(errs...)
 }
 
 func (m *MinIoAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -118,9 +99,9 @@ func (m *MinIoAdapter) GetSignURL(ctx context.Context, object string, expire ...
 		exp = sevenDays
 	}
 	u := &url.URL{}
-	u, err = m.client.PresignedGetObject(m.config.Bucket, objectRel(object), time.Duration(m.config.Expire)*time.Second, nil)
+	u, err = m.client.PresignedGetObject(m.config.Bucket, objectRel(object), time.Duration(exp)*time.Second, nil)
 	if err != nil {
-		return
+		return "", minioError(err)
 	}
 	link = u.String()
 	if !strings.HasPrefix(link, m.config.Domain) {
@@ -129,13 +110,86 @@ func (m *MinIoAdapter) GetSignURL(ctx context.Context, object string, expire ...
 	return
 }
 
+// GetUploadSignURL 生成 PUT 上传链接，ContentType 不参与签名
+func (m *MinIoAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	var u *url.URL
+	exp := uploadExpire(expire, m.config.Expire)
+	if exp > sevenDays {
+		exp = sevenDays
+	}
+	u, err = m.client.PresignedPutObject(m.config.Bucket, objectRel(object), time.Duration(exp)*time.Second)
+	if err != nil {
+		return nil, minioError(err)
+	}
+	return newUploadSign(u.String(), exp, opts), nil
+}
+
+// PostPolicy SDK 的 PostPolicy 不允许空的 starts-with 条件，没有 Key 和 KeyPrefix 时无法生成，
+// 这里与其他平台一样用 policyConditions 生成策略，再按 V4 签名
+func (m *MinIoAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var encoded string
+	exp := uploadExpire(opts.Expire, m.config.Expire)
+	if exp > sevenDays {
+		exp = sevenDays
+	}
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+
+	location, err := m.client.GetBucketLocation(m.config.Bucket)
+	if err != nil {
+		return nil, minioError(err)
+	}
+	now := time.Now().UTC()
+	credential := s3signer.GetCredential(m.config.AccessKey, location, now)
+	date := now.Format("20060102T150405Z")
+	conditions := policyConditions(opts,
+		map[string]string{"bucket": m.config.Bucket},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": date},
+	)
+	if _, encoded, err = encodePolicy(deadline, conditions); err != nil {
+		return
+	}
+	fields := policyFields(opts)
+	fields["bucket"] = m.config.Bucket
+	fields["policy"] = encoded
+	fields["x-amz-algorithm"] = "AWS4-HMAC-SHA256"
+	fields["x-amz-credential"] = credential
+	fields["x-amz-date"] = date
+	fields["x-amz-signature"] = s3signer.PostPresignSignatureV4(encoded, now, m.config.SecretKey, location)
+	return &PostPolicyResult{
+		URL:    "http://" + m.config.Endpoint + "/" + m.config.Bucket + "/",
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
 func (m *MinIoAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
-	obj := &minio.Object{}
-	obj, err = m.client.GetObject(m.config.Bucket, objectRel(object), minio.GetObjectOptions{})
+	return m.DownloadRange(ctx, object, 0, 0)
+}
+
+func (m *MinIoAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	opts := minio.GetObjectOptions{}
+	if length > 0 {
+		err = opts.SetRange(offset, offset+length-1)
+	} else if offset > 0 {
+		err = opts.SetRange(offset, 0)
+	}
 	if err != nil {
 		return
 	}
-	body = obj
+	// Client.GetObject 的 Stat 会去掉 Range，使用 Core.GetObject 立即发起请求，文件不存在等错误也能及时返回
+	core := minio.Core{Client: m.client}
+	body, _, err = core.GetObject(m.config.Bucket, objectRel(object), opts)
+	if err != nil {
+		return nil, minioError(err)
+	}
 	return
 }
 
@@ -145,13 +199,13 @@ func (m *MinIoAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	object = objectRel(object)
 	objInfo, err = m.client.StatObject(m.config.Bucket, object, opts)
 	if err != nil {
-		return
+		return nil, minioError(err)
 	}
 	info = &File{
 		ModTime: objInfo.LastModified,
 		Name:    object,
 		Size:    objInfo.Size,
-		IsDir:   objInfo.Size == 0,
+		IsDir:   isDirKey(object),
 		Header:  make(map[string]string),
 	}
 	for k, _ := range objInfo.Metadata {
@@ -160,23 +214,119 @@ func (m *MinIoAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	return
 }
 
+func (m *MinIoAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	var dstInfo minio.DestinationInfo
+	dstInfo, err = minio.NewDestinationInfo(m.config.Bucket, objectRel(dst), nil, nil)
+	if err != nil {
+		return
+	}
+	srcInfo := minio.NewSourceInfo(m.config.Bucket, objectRel(src), nil)
+	return minioError(m.client.CopyObject(dstInfo, srcInfo))
+}
+
+func (m *MinIoAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	core := minio.Core{Client: m.client}
+	uploadID, err = core.NewMultipartUpload(m.config.Bucket, objectRel(object), minioPutOptions(headers))
+	return uploadID, minioError(err)
+}
+
+func (m *MinIoAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	var res minio.ObjectPart
+	core := minio.Core{Client: m.client}
+	res, err = core.PutObjectPart(m.config.Bucket, objectRel(object), uploadID, number, reader, size, "", "", nil)
+	if err != nil {
+		return nil, minioError(err)
+	}
+	return &Part{Number: res.PartNumber, ETag: res.ETag, Size: size}, nil
+}
+
+func (m *MinIoAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	core := minio.Core{Client: m.client}
+	_, err = core.CompleteMultipartUpload(m.config.Bucket, objectRel(object), uploadID, completeParts)
+	return minioError(err)
+}
+
+func (m *MinIoAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	core := minio.Core{Client: m.client}
+	return minioError(core.AbortMultipartUpload(m.config.Bucket, objectRel(object), uploadID))
+}
+
 func (m *MinIoAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	prefix = objectRel(prefix)
-	doneCh := make(chan struct{})
-	defer close(doneCh)
-	objects := m.client.ListObjectsV2(m.config.Bucket, prefix, true, doneCh)
-	for object := range objects {
-		header := make(map[string]string)
+	return listAll(ctx, m, prefix)
+}
+
+func (m *MinIoAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return m.list(ctx, prefix, "", token, limit)
+}
+
+func (m *MinIoAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, m.list)
+}
+
+func (m *MinIoAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
+	var res minio.ListBucketV2Result
+	core := minio.Core{Client: m.client}
+	res, err = core.ListObjectsV2(m.config.Bucket, objectRel(prefix), token, false, delimiter, limit, "")
+	if err != nil {
+		return nil, minioError(err)
+	}
+	result = &ListResult{}
+	for _, object := range res.Contents {
 		file := &File{
 			ModTime: object.LastModified,
 			Size:    object.Size,
-			IsDir:   object.Size == 0,
+			IsDir:   isDirKey(object.Key),
 			Name:    objectRel(object.Key),
+			Header:  make(map[string]string),
 		}
-		for k, _ := range object.Metadata {
-			header[k] = object.Metadata.Get(k)
+		for k := range object.Metadata {
+			file.Header[k] = object.Metadata.Get(k)
 		}
-		files = append(files, file)
+		result.Files = append(result.Files, file)
+	}
+	for _, commonPrefix := range res.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix.Prefix))
+	}
+	if res.IsTruncated {
+		result.NextToken = res.NextContinuationToken
 	}
 	return
 }
+
+// minioPutOptions 把header转换为minio的上传参数
+func minioPutOptions(headers ...map[string]string) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		UserMetadata: make(map[string]string),
+	}
+
+	for _, header := range headers {
+		for k, v := range header {
+			switch strings.ToLower(k) {
+			case "content-disposition":
+				opts.ContentDisposition = v
+			case "content-encoding":
+				opts.ContentEncoding = v
+			case "content-type":
+				opts.ContentType = v
+			default:
+				opts.UserMetadata[k] = v
+			}
+		}
+	}
+	return opts
+}
+
+// minioError 对minio返回的错误进行归类
+func minioError(err error) error {
+	if resp := minio.ToErrorResponse(err); resp.StatusCode != 0 {
+		return statusError(resp.StatusCode, err)
+	}
+	return err
+}
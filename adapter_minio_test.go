@@ -0,0 +1,86 @@
+package filesys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// hmacSha256 计算 HMAC-SHA256
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func TestMinioAdapterPostPolicy(t *testing.T) {
+	// 生成策略时只会查询存储桶所在的区域
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/bucket/" || !r.URL.Query().Has("location") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">cn-north-1</LocationConstraint>`))
+	}))
+	defer server.Close()
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	adapter, err := NewAdapterMinio(ConfigMinio{AccessKey: "access", SecretKey: "secret", Endpoint: endpoint, Bucket: "bucket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts    *PostPolicyOptions
+		key     string
+		keyCond []interface{}
+	}{
+		{nil, "${filename}", []interface{}{"starts-with", "$key", ""}},
+		{&PostPolicyOptions{KeyPrefix: "/upload/"}, "upload/${filename}", []interface{}{"starts-with", "$key", "upload/"}},
+		{&PostPolicyOptions{Key: "a.txt", MaxSize: 10}, "a.txt", []interface{}{"eq", "$key", "a.txt"}},
+	}
+	for _, tt := range tests {
+		policy, err := adapter.(PostPolicySigner).PostPolicy(context.Background(), tt.opts)
+		if err != nil {
+			t.Fatalf("PostPolicy(%+v): %v", tt.opts, err)
+		}
+		fields := policy.Fields
+		if policy.URL != server.URL+"/bucket/" || fields["key"] != tt.key || fields["bucket"] != "bucket" {
+			t.Errorf("PostPolicy(%+v) = %s %v", tt.opts, policy.URL, fields)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(fields["policy"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc struct {
+			Conditions []interface{} `json:"conditions"`
+		}
+		if err = json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		conditions, _ := json.Marshal(doc.Conditions)
+		keyCond, _ := json.Marshal(tt.keyCond)
+		if !strings.Contains(string(conditions), string(keyCond)) || !strings.Contains(string(conditions), `{"x-amz-credential":"`+fields["x-amz-credential"]+`"}`) {
+			t.Errorf("PostPolicy(%+v) conditions = %s", tt.opts, conditions)
+		}
+
+		// 按 V4 签名校验
+		day := fields["x-amz-date"][:8]
+		if want := "access/" + day + "/cn-north-1/s3/aws4_request"; fields["x-amz-credential"] != want {
+			t.Errorf("x-amz-credential = %s, want %s", fields["x-amz-credential"], want)
+		}
+		key := hmacSha256([]byte("AWS4secret"), day)
+		for _, part := range []string{"cn-north-1", "s3", "aws4_request"} {
+			key = hmacSha256(key, part)
+		}
+		if want := hex.EncodeToString(hmacSha256(key, fields["policy"])); fields["x-amz-signature"] != want {
+			t.Errorf("x-amz-signature = %s, want %s", fields["x-amz-signature"], want)
+		}
+	}
+}
@@ -2,14 +2,19 @@ package filesys
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type ConfigObs struct {
@@ -50,6 +55,11 @@ func NewAdapterObs(i interface{}) (Adapter, error) {
 	return o, err
 }
 
+// Bucket 存储桶名称
+func (o *ObsAdapter) Bucket() string {
+	return o.config.Bucket
+}
+
 func (o *ObsAdapter) IsExist(ctx context.Context, object string) (err error) {
 	_, err = o.GetInfo(ctx, object)
 	return
@@ -61,6 +71,10 @@ func (o *ObsAdapter) Upload(ctx context.Context, path string, reader io.Reader,
 	input.Key = objectRel(path)
 	input.Metadata = make(map[string]string)
 	input.Body = reader
+	if size >= 0 {
+		// 中间件包装后SDK无法从 reader 的类型获取长度
+		input.ContentLength = size
+	}
 
 	for _, header := range headers {
 		for k, v := range header {
@@ -73,7 +87,7 @@ func (o *ObsAdapter) Upload(ctx context.Context, path string, reader io.Reader,
 		}
 	}
 	_, err = o.client.PutObject(input)
-	return
+	return obsError(err)
 }
 
 func (o *ObsAdapter) Delete(ctx context.Context, objects ...string) (err error) {
@@ -90,8 +104,15 @@ func (o *ObsAdapter) Delete(ctx context.Context, objects ...string) (err error)
 		Bucket:  o.config.Bucket,
 		Objects: objs,
 	}
-	_, err = o.client.DeleteObjects(input)
-	return
+	output, err := o.client.DeleteObjects(input)
+	if err != nil {
+		return obsError(err)
+	}
+	var errs []error
+	for _, item := range output.Errors {
+		errs = append(errs, obsDeleteError(item))
+	}
+	return joinErrors(errs...)
 }
 
 func (o *ObsAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -112,7 +133,7 @@ func (o *ObsAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	output := &obs.CreateSignedUrlOutput{}
 	output, err = o.client.CreateSignedUrl(input)
 	if err != nil {
-		return
+		return "", obsError(err)
 	}
 	link = output.SignedUrl
 	if !strings.HasPrefix(link, o.config.Domain) {
@@ -123,6 +144,52 @@ func (o *ObsAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	return
 }
 
+// GetUploadSignURL 生成 PUT 上传链接，指定 ContentType 时会参与签名
+func (o *ObsAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	exp := uploadExpire(expire, o.config.Expire)
+	input := &obs.CreateSignedUrlInput{
+		Method:  http.MethodPut,
+		Bucket:  o.config.Bucket,
+		Key:     objectRel(object),
+		Expires: int(exp),
+		Headers: map[string]string{},
+	}
+	if opts.ContentType != "" {
+		input.Headers["Content-Type"] = opts.ContentType
+	}
+	output, err := o.client.CreateSignedUrl(input)
+	if err != nil {
+		return nil, obsError(err)
+	}
+	return newUploadSign(output.SignedUrl, exp, opts), nil
+}
+
+// PostPolicy https://support.huaweicloud.com/api-obs/obs_04_0012.html
+func (o *ObsAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var encoded string
+	exp := uploadExpire(opts.Expire, o.config.Expire)
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+	conditions := policyConditions(opts, map[string]string{"bucket": o.config.Bucket})
+	if _, encoded, err = encodePolicy(deadline, conditions); err != nil {
+		return
+	}
+	fields := policyFields(opts)
+	fields["AccessKeyId"] = o.config.AccessKey
+	fields["policy"] = encoded
+	fields["signature"] = base64.StdEncoding.EncodeToString(hmacSha1(o.config.SecretKey, encoded))
+	return &PostPolicyResult{
+		URL:    bucketEndpoint(o.config.Bucket, o.config.Endpoint),
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
 func (o *ObsAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	input := &obs.GetObjectInput{}
 	input.Key = objectRel(object)
@@ -130,12 +197,33 @@ func (o *ObsAdapter) Download(ctx context.Context, object string) (body io.ReadC
 
 	output, err := o.client.GetObject(input)
 	if err != nil {
-		return
+		return nil, obsError(err)
 	}
 	body = output.Body
 	return
 }
 
+func (o *ObsAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	input := &obs.GetObjectInput{}
+	input.Key = objectRel(object)
+	input.Bucket = o.config.Bucket
+	// SDK 只有在 RangeEnd 大于 RangeStart 时才会设置 Range，多读取的部分由 limitBody 截断
+	input.RangeStart = offset
+	input.RangeEnd = math.MaxInt64
+	if length > 1 {
+		input.RangeEnd = offset + length - 1
+	} else if length == 1 {
+		input.RangeEnd = offset + 1
+	}
+
+	output, err := o.client.GetObject(input)
+	if err != nil {
+		return nil, obsError(err)
+	}
+	body = limitBody(output.Body, length)
+	return
+}
+
 func (o *ObsAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
 	input := &obs.GetObjectMetadataInput{
 		Bucket: o.config.Bucket,
@@ -144,36 +232,153 @@ func (o *ObsAdapter) GetInfo(ctx context.Context, object string) (info *File, er
 	output := &obs.GetObjectMetadataOutput{}
 	output, err = o.client.GetObjectMetadata(input)
 	if err != nil {
-		return
+		return nil, obsError(err)
 	}
 	info = &File{
 		Name:    objectRel(object),
 		Size:    output.ContentLength,
-		IsDir:   output.ContentLength == 0,
+		IsDir:   isDirKey(object),
 		ModTime: output.LastModified,
 	}
 	return
 }
 
+func (o *ObsAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	input := &obs.CopyObjectInput{}
+	input.Bucket = o.config.Bucket
+	input.Key = objectRel(dst)
+	input.CopySourceBucket = o.config.Bucket
+	input.CopySourceKey = objectRel(src)
+	_, err = o.client.CopyObject(input)
+	return obsError(err)
+}
+
+func (o *ObsAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	input := &obs.InitiateMultipartUploadInput{}
+	input.Bucket = o.config.Bucket
+	input.Key = objectRel(object)
+	input.Metadata = make(map[string]string)
+	for k, v := range headers {
+		switch strings.ToLower(k) {
+		case "content-type":
+			input.ContentType = v
+		default:
+			input.Metadata[k] = v
+		}
+	}
+	output, err := o.client.InitiateMultipartUpload(input)
+	if err != nil {
+		return "", obsError(err)
+	}
+	return output.UploadId, nil
+}
+
+func (o *ObsAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	input := &obs.UploadPartInput{
+		Bucket:     o.config.Bucket,
+		Key:        objectRel(object),
+		PartNumber: number,
+		UploadId:   uploadID,
+		Body:       reader,
+		PartSize:   size,
+	}
+	output, err := o.client.UploadPart(input)
+	if err != nil {
+		return nil, obsError(err)
+	}
+	return &Part{Number: number, ETag: output.ETag, Size: size}, nil
+}
+
+func (o *ObsAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	input := &obs.CompleteMultipartUploadInput{
+		Bucket:   o.config.Bucket,
+		Key:      objectRel(object),
+		UploadId: uploadID,
+	}
+	for _, part := range parts {
+		input.Parts = append(input.Parts, obs.Part{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err = o.client.CompleteMultipartUpload(input)
+	return obsError(err)
+}
+
+func (o *ObsAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	input := &obs.AbortMultipartUploadInput{
+		Bucket:   o.config.Bucket,
+		Key:      objectRel(object),
+		UploadId: uploadID,
+	}
+	_, err = o.client.AbortMultipartUpload(input)
+	return obsError(err)
+}
+
 func (o *ObsAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	prefix = objectRel(prefix)
+	return listAll(ctx, o, prefix)
+}
+
+func (o *ObsAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return o.list(ctx, prefix, "", token, limit)
+}
+
+func (o *ObsAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, o.list)
+}
+
+func (o *ObsAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
 	input := &obs.ListObjectsInput{}
-	input.Prefix = prefix
+	input.Prefix = objectRel(prefix)
+	input.Delimiter = delimiter
+	input.MaxKeys = limit
+	input.Marker = token
 	input.Bucket = o.config.Bucket
 	output := &obs.ListObjectsOutput{}
 	output, err = o.client.ListObjects(input)
 	if err != nil {
-		return
+		return nil, obsError(err)
 	}
 
+	result = &ListResult{}
+	lastKey := ""
 	for _, item := range output.Contents {
-		files = append(files, &File{
+		result.Files = append(result.Files, &File{
 			ModTime: item.LastModified,
 			Name:    objectRel(item.Key),
 			Size:    item.Size,
-			IsDir:   item.Size == 0,
+			IsDir:   isDirKey(item.Key),
 		})
+		lastKey = item.Key
 	}
-
+	for _, commonPrefix := range output.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix))
+	}
+	result.NextToken = nextMarker(output.IsTruncated, output.NextMarker, lastKey)
 	return
 }
+
+// obsError 对华为云返回的错误进行归类
+func obsError(err error) error {
+	var respErr obs.ObsError
+	if errors.As(err, &respErr) {
+		return statusError(respErr.StatusCode, err)
+	}
+	return err
+}
+
+// obsDeleteError 对批量删除中单个文件的错误码进行归类
+func obsDeleteError(item obs.Error) error {
+	err := gerror.Newf("%s: %s %s", item.Key, item.Code, item.Message)
+	switch item.Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return wrapError(ErrNotExist, err)
+	case "AccessDenied":
+		return wrapError(ErrPermission, err)
+	case "InvalidObjectName":
+		return wrapError(ErrInvalidPath, err)
+	case "InternalError", "ServiceUnavailable", "RequestTimeout", "SlowDown":
+		return temporaryError(err)
+	}
+	return err
+}
@@ -2,7 +2,9 @@ package filesys
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"io"
@@ -59,40 +61,36 @@ func NewAdapterOss(i interface{}) (Adapter, error) {
 	return o, err
 }
 
+// Bucket 存储桶名称
+func (o *OssAdapter) Bucket() string {
+	return o.config.Bucket
+}
+
 func (o *OssAdapter) IsExist(ctx context.Context, object string) (err error) {
 	var b bool
 	b, err = o.client.IsObjectExist(objectRel(object))
 	if err != nil {
-		return
+		return ossError(err)
 	}
 	if !b {
-		return errors.New("file is not exist")
+		return notExistError(object)
 	}
 	return
 }
 
 func (o *OssAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
-	var opts []oss.Option
-	for _, header := range headers {
-		for k, v := range header {
-			switch strings.ToLower(k) {
-			case "content-type":
-				opts = append(opts, oss.ContentType(v))
-			case "content-encoding":
-				opts = append(opts, oss.ContentEncoding(v))
-			case "content-disposition":
-				opts = append(opts, oss.ContentDisposition(v))
-				// TODO: more
-			}
-		}
+	options := ossOptions(headers...)
+	if size >= 0 {
+		// 中间件包装后SDK无法从 reader 的类型获取长度
+		options = append(options, oss.ContentLength(size))
 	}
-	err = o.client.PutObject(strings.TrimLeft(path, "./"), reader, opts...)
+	err = ossError(o.client.PutObject(strings.TrimLeft(path, "./"), reader, options...))
 	return
 }
 
 func (o *OssAdapter) Delete(ctx context.Context, objects ...string) (err error) {
 	_, err = o.client.DeleteObjects(objects)
-	return
+	return ossError(err)
 }
 
 func (o *OssAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -106,7 +104,7 @@ func (o *OssAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	}
 	link, err = o.client.SignURL(path, http.MethodGet, exp)
 	if err != nil {
-		return
+		return "", ossError(err)
 	}
 	if !strings.HasPrefix(link, o.config.Domain) {
 		if u, errU := url.Parse(link); errU == nil {
@@ -117,9 +115,64 @@ func (o *OssAdapter) GetSignURL(ctx context.Context, object string, expire ...in
 	return
 }
 
+// GetUploadSignURL 生成 PUT 上传链接，指定 ContentType 时会参与签名
+func (o *OssAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	var (
+		link    string
+		options []oss.Option
+	)
+	exp := uploadExpire(expire, o.config.Expire)
+	if opts.ContentType != "" {
+		options = append(options, oss.ContentType(opts.ContentType))
+	}
+	link, err = o.client.SignURL(objectRel(object), oss.HTTPPut, exp, options...)
+	if err != nil {
+		return nil, ossError(err)
+	}
+	return newUploadSign(link, exp, opts), nil
+}
+
+// PostPolicy https://help.aliyun.com/document_detail/31988.html
+func (o *OssAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var encoded string
+	exp := uploadExpire(opts.Expire, o.config.Expire)
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+	conditions := policyConditions(opts, map[string]string{"bucket": o.config.Bucket})
+	if _, encoded, err = encodePolicy(deadline, conditions); err != nil {
+		return
+	}
+	fields := policyFields(opts)
+	fields["OSSAccessKeyId"] = o.config.AccessKey
+	fields["policy"] = encoded
+	fields["Signature"] = base64.StdEncoding.EncodeToString(hmacSha1(o.config.SecretKey, encoded))
+	fields["success_action_status"] = "200"
+	return &PostPolicyResult{
+		URL:    bucketEndpoint(o.config.Bucket, o.config.Endpoint),
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
 func (o *OssAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	body, err = o.client.GetObject(objectRel(object))
-	return
+	return body, ossError(err)
+}
+
+func (o *OssAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	opts := []oss.Option{oss.RangeBehavior("standard")}
+	if length > 0 {
+		opts = append(opts, oss.Range(offset, offset+length-1))
+	} else {
+		opts = append(opts, oss.NormalizedRange(fmt.Sprintf("%d-", offset)))
+	}
+	body, err = o.client.GetObject(objectRel(object), opts...)
+	return body, ossError(err)
 }
 
 func (o *OssAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
@@ -138,7 +191,7 @@ func (o *OssAdapter) GetInfo(ctx context.Context, object string) (info *File, er
 	path := objectRel(object)
 	header, err = o.client.GetObjectMeta(path)
 	if err != nil {
-		return
+		return nil, ossError(err)
 	}
 
 	headerMap := make(map[string]string)
@@ -155,23 +208,114 @@ func (o *OssAdapter) GetInfo(ctx context.Context, object string) (info *File, er
 	return
 }
 
+func (o *OssAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	_, err = o.client.CopyObject(objectRel(src), objectRel(dst))
+	return ossError(err)
+}
+
+func (o *OssAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	var imur oss.InitiateMultipartUploadResult
+	imur, err = o.client.InitiateMultipartUpload(objectRel(object), ossOptions(headers)...)
+	if err != nil {
+		return "", ossError(err)
+	}
+	return imur.UploadID, nil
+}
+
+func (o *OssAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	var res oss.UploadPart
+	res, err = o.client.UploadPart(o.multipartResult(object, uploadID), reader, size, number)
+	if err != nil {
+		return nil, ossError(err)
+	}
+	return &Part{Number: res.PartNumber, ETag: res.ETag, Size: size}, nil
+}
+
+func (o *OssAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	uploadParts := make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		uploadParts = append(uploadParts, oss.UploadPart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err = o.client.CompleteMultipartUpload(o.multipartResult(object, uploadID), uploadParts)
+	return ossError(err)
+}
+
+func (o *OssAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	return ossError(o.client.AbortMultipartUpload(o.multipartResult(object, uploadID)))
+}
+
+func (o *OssAdapter) multipartResult(object, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{
+		Bucket:   o.config.Bucket,
+		Key:      objectRel(object),
+		UploadID: uploadID,
+	}
+}
+
 func (o *OssAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	prefix = objectRel(prefix)
+	return listAll(ctx, o, prefix)
+}
+
+func (o *OssAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return o.list(ctx, prefix, "", token, limit)
+}
+
+func (o *OssAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, o.list)
+}
 
+func (o *OssAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
 	var res oss.ListObjectsResult
 
-	res, err = o.client.ListObjects(oss.Prefix(prefix))
+	res, err = o.client.ListObjects(oss.Prefix(objectRel(prefix)), oss.Delimiter(delimiter), oss.Marker(token), oss.MaxKeys(limit))
 	if err != nil {
-		return
+		return nil, ossError(err)
 	}
+	result = &ListResult{}
+	lastKey := ""
 	for _, object := range res.Objects {
-		files = append(files, &File{
+		result.Files = append(result.Files, &File{
 			ModTime: object.LastModified,
 			Name:    object.Key,
 			Size:    object.Size,
-			IsDir:   object.Size == 0,
+			IsDir:   isDirKey(object.Key),
 			Header:  map[string]string{},
 		})
+		lastKey = object.Key
+	}
+	for _, commonPrefix := range res.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix))
 	}
+	result.NextToken = nextMarker(res.IsTruncated, res.NextMarker, lastKey)
 	return
 }
+
+// ossOptions 把header转换为阿里云的请求参数
+func ossOptions(headers ...map[string]string) (opts []oss.Option) {
+	for _, header := range headers {
+		for k, v := range header {
+			switch strings.ToLower(k) {
+			case "content-type":
+				opts = append(opts, oss.ContentType(v))
+			case "content-encoding":
+				opts = append(opts, oss.ContentEncoding(v))
+			case "content-disposition":
+				opts = append(opts, oss.ContentDisposition(v))
+				// TODO: more
+			}
+		}
+	}
+	return
+}
+
+// ossError 对阿里云返回的错误进行归类
+func ossError(err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		return statusError(serviceErr.StatusCode, err)
+	}
+	return err
+}
@@ -0,0 +1,398 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix 删除下层文件时，在最上层写入的标记文件的前缀，
+// 不使用 aufs 的 ".wh." 是因为 objectRel 会去掉路径开头的 "."
+const whiteoutPrefix = "_wh_."
+
+// OverlayAdapter 把多个适配器叠加为一个，第一个适配器为最上层，
+// 读取时从上往下查找，写入只写最上层，删除下层的文件时在最上层写入标记文件
+type OverlayAdapter struct {
+	layers []Adapter
+}
+
+// NewAdapterOverlay 按从上到下的顺序叠加适配器，最上层需要可写
+func NewAdapterOverlay(layers ...Adapter) (Adapter, error) {
+	if len(layers) == 0 {
+		return nil, gerror.New("至少需要一个适配器")
+	}
+	for i, layer := range layers {
+		if layer == nil {
+			return nil, gerror.Newf("第%d层适配器不能为空", i+1)
+		}
+	}
+	return &OverlayAdapter{layers: layers}, nil
+}
+
+// Layers 按从上到下的顺序返回全部适配器
+func (o *OverlayAdapter) Layers() []Adapter {
+	return o.layers
+}
+
+// top 可写的最上层
+func (o *OverlayAdapter) top() Adapter {
+	return o.layers[0]
+}
+
+// whiteout 文件对应的标记文件路径
+func whiteout(object string) string {
+	object = objectRel(object)
+	dir, name := path.Split(object)
+	return dir + whiteoutPrefix + name
+}
+
+// isWhiteout 判断是否为标记文件，返回被删除的文件路径
+func isWhiteout(object string) (string, bool) {
+	dir, name := path.Split(objectRel(object))
+	if !strings.HasPrefix(name, whiteoutPrefix) {
+		return "", false
+	}
+	return dir + strings.TrimPrefix(name, whiteoutPrefix), true
+}
+
+// resolve 从上往下查找文件所在的层，文件在下层且已被删除时返回 ErrNotExist
+func (o *OverlayAdapter) resolve(ctx context.Context, object string) (layer Adapter, info *File, err error) {
+	if _, ok := isWhiteout(object); ok {
+		return nil, nil, notExistError(object)
+	}
+	for i := range o.layers {
+		if i == 1 {
+			if err = o.top().IsExist(ctx, whiteout(object)); err == nil {
+				return nil, nil, notExistError(object)
+			} else if !errors.Is(err, ErrNotExist) {
+				return
+			}
+		}
+		if info, err = o.layers[i].GetInfo(ctx, object); err == nil {
+			return o.layers[i], info, nil
+		} else if !errors.Is(err, ErrNotExist) {
+			return
+		}
+	}
+	return nil, nil, notExistError(object)
+}
+
+// existsBelow 判断下层是否存在该文件，不考虑标记文件
+func (o *OverlayAdapter) existsBelow(ctx context.Context, object string) (bool, error) {
+	for _, layer := range o.layers[1:] {
+		err := layer.IsExist(ctx, object)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// unwhiteout 写入文件后删除对应的标记文件
+func (o *OverlayAdapter) unwhiteout(ctx context.Context, object string) (err error) {
+	if len(o.layers) == 1 {
+		return
+	}
+	if err = o.top().Delete(ctx, whiteout(object)); errors.Is(err, ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (o *OverlayAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, _, err = o.resolve(ctx, object)
+	return
+}
+
+func (o *OverlayAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	if _, ok := isWhiteout(path); ok {
+		return invalidPathError(path)
+	}
+	if err = o.top().Upload(ctx, path, reader, size, headers...); err != nil {
+		return
+	}
+	return o.unwhiteout(ctx, path)
+}
+
+// Delete 删除最上层的文件，下层存在同名文件时写入标记文件将其隐藏
+func (o *OverlayAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	if err = o.top().Delete(ctx, objects...); err != nil && !errors.Is(err, ErrNotExist) {
+		return
+	}
+	for _, object := range objects {
+		exists, err := o.existsBelow(ctx, object)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err = o.top().Upload(ctx, whiteout(object), bytes.NewReader(nil), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OverlayAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	layer, _, err := o.resolve(ctx, object)
+	if errors.Is(err, ErrNotExist) {
+		// 文件不存在时返回最上层的链接
+		layer, err = o.top(), nil
+	}
+	if err != nil {
+		return
+	}
+	return layer.GetSignURL(ctx, object, expire...)
+}
+
+func (o *OverlayAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	layer, _, err := o.resolve(ctx, object)
+	if err != nil {
+		return
+	}
+	return layer.Download(ctx, object)
+}
+
+func (o *OverlayAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	layer, _, err := o.resolve(ctx, object)
+	if err != nil {
+		return
+	}
+	return downloadRange(ctx, layer, object, offset, length)
+}
+
+func (o *OverlayAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	_, info, err = o.resolve(ctx, object)
+	return
+}
+
+// Copy 源文件在最上层时在最上层复制，否则从所在层下载后写入最上层
+func (o *OverlayAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	layer, info, err := o.resolve(ctx, src)
+	if err != nil {
+		return
+	}
+	if layer == o.top() {
+		err = copyObject(ctx, layer, src, dst)
+	} else {
+		var body io.ReadCloser
+		if body, err = layer.Download(ctx, src); err != nil {
+			return
+		}
+		defer body.Close()
+		err = o.top().Upload(ctx, dst, body, info.Size, copyHeaders(info.Header))
+	}
+	if err != nil {
+		return
+	}
+	return o.unwhiteout(ctx, dst)
+}
+
+// Move 复制后删除源文件，源文件和目标文件相同时只检查文件是否存在
+func (o *OverlayAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	if objectRel(src) == objectRel(dst) {
+		return o.IsExist(ctx, src)
+	}
+	if err = o.Copy(ctx, src, dst); err != nil {
+		return
+	}
+	return o.Delete(ctx, src)
+}
+
+// Lists 合并各层的文件，同名文件以上层为准，并排除已删除的文件
+func (o *OverlayAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return o.merge(ctx, func(layer Adapter) ([]*File, error) {
+		return layer.Lists(ctx, prefix)
+	})
+}
+
+// ListPage 按文件名合并各层的分页结果，同名文件以上层为准，并排除已删除的文件。
+// NextToken 记录上一页最后的文件名和各层当前页的标记，各层的标记可以是任意格式
+func (o *OverlayAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	after, cursors, err := o.decodeToken(token)
+	if err != nil {
+		return
+	}
+	result = &ListResult{}
+	for {
+		var (
+			next  *File
+			index int
+		)
+		for i, cursor := range cursors {
+			var file *File
+			if file, err = cursor.peek(ctx, prefix, after, limit); err != nil {
+				return nil, err
+			}
+			if file != nil && (next == nil || objectRel(file.Name) < objectRel(next.Name)) {
+				next, index = file, i
+			}
+		}
+		if next == nil {
+			return
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = encodeOverlayToken(after, cursors)
+			return
+		}
+		name := objectRel(next.Name)
+		after = name
+		if index == 0 {
+			if _, ok := isWhiteout(name); ok {
+				continue
+			}
+		} else if !next.IsDir {
+			// 下层的文件需要检查是否已被删除
+			if err = o.top().IsExist(ctx, whiteout(name)); err == nil {
+				continue
+			} else if !errors.Is(err, ErrNotExist) {
+				return nil, err
+			}
+			err = nil
+		}
+		result.Files = append(result.Files, next)
+	}
+}
+
+// overlayToken 合并分页的标记
+type overlayToken struct {
+	After  string   `json:"after"`  // 上一页最后的文件名
+	Tokens []string `json:"tokens"` // 各层当前页的标记，当前页可能只使用了一部分，续传时重新获取并跳过不大于 After 的文件
+	Done   []bool   `json:"done"`   // 各层是否已经没有更多文件
+}
+
+// overlayCursor 逐个读取一层的分页结果
+type overlayCursor struct {
+	layer  Adapter
+	token  string  // 当前页的标记
+	files  []*File // 当前页未读取的文件
+	next   string  // 下一页的标记
+	loaded bool    // 当前页是否已获取
+	done   bool    // 没有更多文件
+}
+
+// peek 返回该层第一个大于 after 的文件，当前页读完时获取下一页，没有更多文件时返回 nil
+func (c *overlayCursor) peek(ctx context.Context, prefix, after string, limit int) (*File, error) {
+	for {
+		for len(c.files) > 0 && objectRel(c.files[0].Name) <= after {
+			c.files = c.files[1:]
+		}
+		if len(c.files) > 0 {
+			return c.files[0], nil
+		}
+		if c.done {
+			return nil, nil
+		}
+		if c.loaded {
+			if c.next == "" {
+				c.done = true
+				return nil, nil
+			}
+			c.token = c.next
+		}
+		res, err := c.list(ctx, prefix, limit)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(res.Files, func(i, j int) bool {
+			return res.Files[i].Name < res.Files[j].Name
+		})
+		c.files, c.next, c.loaded = res.Files, res.NextToken, true
+	}
+}
+
+// list 获取该层的下一页，按目录层级顺序分页的层不能按文件名逐页合并，
+// 每次都列出前缀下的全部文件，由 peek 排序后跳过不大于 After 的文件
+func (c *overlayCursor) list(ctx context.Context, prefix string, limit int) (*ListResult, error) {
+	if _, ok := innerAdapter(c.layer).(pathOrderLister); !ok {
+		return listPage(ctx, c.layer, prefix, c.token, limit)
+	}
+	files, err := c.layer.Lists(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResult{Files: files}, nil
+}
+
+// decodeToken 解析 ListPage 的标记，为空时从各层的第一页开始
+func (o *OverlayAdapter) decodeToken(token string) (after string, cursors []*overlayCursor, err error) {
+	state := overlayToken{Tokens: make([]string, len(o.layers)), Done: make([]bool, len(o.layers))}
+	if token != "" {
+		data, errDecode := base64.RawURLEncoding.DecodeString(token)
+		if errDecode == nil {
+			errDecode = json.Unmarshal(data, &state)
+		}
+		if errDecode != nil || len(state.Tokens) != len(o.layers) || len(state.Done) != len(o.layers) {
+			return "", nil, wrapError(ErrInvalidPath, gerror.Newf("分页标记[%s]不合法", token))
+		}
+	}
+	for i, layer := range o.layers {
+		cursors = append(cursors, &overlayCursor{layer: layer, token: state.Tokens[i], done: state.Done[i]})
+	}
+	return state.After, cursors, nil
+}
+
+// encodeOverlayToken 生成 ListPage 的标记
+func encodeOverlayToken(after string, cursors []*overlayCursor) string {
+	state := overlayToken{After: after}
+	for _, cursor := range cursors {
+		state.Tokens = append(state.Tokens, cursor.token)
+		state.Done = append(state.Done, cursor.done)
+	}
+	data, _ := json.Marshal(state)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ListDir 合并各层目录下的文件和子目录
+func (o *OverlayAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return o.merge(ctx, func(layer Adapter) ([]*File, error) {
+		return listDir(ctx, layer, prefix)
+	})
+}
+
+// merge 合并各层的列表结果，按文件名排序
+func (o *OverlayAdapter) merge(ctx context.Context, list func(layer Adapter) ([]*File, error)) (files []*File, err error) {
+	var (
+		seen    = make(map[string]bool)
+		deleted = make(map[string]bool)
+	)
+	for i, layer := range o.layers {
+		var layerFiles []*File
+		if layerFiles, err = list(layer); err != nil {
+			return nil, err
+		}
+		for _, file := range layerFiles {
+			name := objectRel(file.Name)
+			if i == 0 {
+				if object, ok := isWhiteout(name); ok {
+					deleted[object] = true
+					continue
+				}
+			}
+			// 目录不会被标记删除，只有同名时才去重
+			if seen[name] || (!file.IsDir && deleted[name]) {
+				continue
+			}
+			seen[name] = true
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return
+}
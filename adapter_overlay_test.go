@@ -0,0 +1,228 @@
+package filesys
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// opaqueTokenAdapter 分页标记不是文件名，模拟使用 continuation token 的对象存储
+type opaqueTokenAdapter struct {
+	*MemoryAdapter
+}
+
+func (a *opaqueTokenAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (*ListResult, error) {
+	name, err := hex.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	result, err := a.MemoryAdapter.ListPage(ctx, prefix, string(name), limit)
+	if err != nil {
+		return nil, err
+	}
+	if result.NextToken != "" {
+		result.NextToken = hex.EncodeToString([]byte(result.NextToken))
+	}
+	return result, nil
+}
+
+// newMemoryLayer 创建内存适配器，文件内容为文件名
+func newMemoryLayer(t *testing.T, names ...string) *MemoryAdapter {
+	files := make(map[string]string)
+	for _, name := range names {
+		files[name] = name
+	}
+	return newMemoryAdapter(t, files)
+}
+
+func TestOverlayAdapterListPage(t *testing.T) {
+	ctx := context.Background()
+	top := newMemoryLayer(t, "a/1", "a/3", "b/1", "c")
+	middle := newMemoryLayer(t, "a/2", "a/3", "a/4", "b/2", "d")
+	bottom := newMemoryLayer(t, "a/5", "b/1", "b/3", "e")
+	adapter, _ := NewAdapterOverlay(top, &opaqueTokenAdapter{middle}, bottom)
+	overlay := adapter.(*OverlayAdapter)
+	if err := overlay.Delete(ctx, "a/4", "b/3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := top.Upload(ctx, "a/3", strings.NewReader("top layer"), 9); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"a/1", "a/2", "a/3", "a/5", "b/1", "b/2", "c", "d", "e"}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", want},
+		{"a/", want[:4]},
+		{"b", want[4:6]},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 100} {
+			var (
+				got   []string
+				token string
+			)
+			for page := 0; ; page++ {
+				if page > len(want) {
+					t.Fatalf("prefix %q limit %d: too many pages", tt.prefix, limit)
+				}
+				result, err := overlay.ListPage(ctx, tt.prefix, token, limit)
+				if err != nil {
+					t.Fatalf("prefix %q limit %d: %v", tt.prefix, limit, err)
+				}
+				if len(result.Files) > limit {
+					t.Fatalf("prefix %q limit %d: page has %d files", tt.prefix, limit, len(result.Files))
+				}
+				for _, file := range result.Files {
+					got = append(got, file.Name)
+				}
+				if token = result.NextToken; token == "" {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prefix %q limit %d: got %v, want %v", tt.prefix, limit, got, tt.want)
+			}
+		}
+	}
+
+	// 上层的同名文件优先
+	result, err := overlay.ListPage(ctx, "a/3", "", 10)
+	if err != nil || len(result.Files) != 1 || result.Files[0].Size != 9 {
+		t.Fatalf("ListPage(a/3) = %v, %v", result, err)
+	}
+	if _, err = overlay.ListPage(ctx, "", "invalid", 10); err == nil {
+		t.Fatal("ListPage with invalid token: want error")
+	}
+}
+
+func TestOverlayAdapterListPagePathOrder(t *testing.T) {
+	ctx := context.Background()
+	// 本地适配器按目录层级顺序分页，"a/b" 在 "a.txt" 之前
+	local, err := NewAdapterLocal(ConfigLocal{Path: t.TempDir(), IsDev: "true", Domain: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a/b", "a.txt", "c/d"} {
+		if err = local.Upload(ctx, name, strings.NewReader(name), int64(len(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adapter, _ := NewAdapterOverlay(local, newMemoryLayer(t, "a-b", "a/a", "b"))
+	overlay := adapter.(*OverlayAdapter)
+
+	want := []string{"a-b", "a.txt", "a/a", "a/b", "b", "c/d"}
+	for _, limit := range []int{1, 2, 100} {
+		var (
+			got   []string
+			token string
+		)
+		for page := 0; ; page++ {
+			if page > len(want) {
+				t.Fatalf("limit %d: too many pages", limit)
+			}
+			result, err := overlay.ListPage(ctx, "", token, limit)
+			if err != nil {
+				t.Fatalf("limit %d: %v", limit, err)
+			}
+			for _, file := range result.Files {
+				got = append(got, file.Name)
+			}
+			if token = result.NextToken; token == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("limit %d: got %v, want %v", limit, got, want)
+		}
+	}
+}
+
+func TestOverlayAdapter(t *testing.T) {
+	ctx := context.Background()
+	top := newMemoryAdapter(t, map[string]string{"a.txt": "top a", "dir/top.txt": "top"})
+	bottom := newMemoryAdapter(t, map[string]string{"a.txt": "bottom a", "b.txt": "bottom b", "dir/bottom.txt": "bottom", "c.txt": "c"})
+	adapter, err := NewAdapterOverlay(top, bottom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 从上往下读取
+	if got := readObject(t, adapter, "a.txt"); got != "top a" {
+		t.Errorf("a.txt = %q, want the top layer", got)
+	}
+	if got := readObject(t, adapter, "b.txt"); got != "bottom b" {
+		t.Errorf("b.txt = %q, want the bottom layer", got)
+	}
+
+	// 删除下层的文件写入标记文件，不修改下层
+	if err = adapter.Delete(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err = adapter.IsExist(ctx, name); !errors.Is(err, ErrNotExist) {
+			t.Errorf("IsExist(%s) after Delete: got %v, want ErrNotExist", name, err)
+		}
+		if err = bottom.IsExist(ctx, name); err != nil {
+			t.Errorf("bottom layer %s deleted: %v", name, err)
+		}
+	}
+	if _, err = adapter.Download(ctx, whiteout("b.txt")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Download whiteout: got %v, want ErrNotExist", err)
+	}
+	if err = adapter.Upload(ctx, whiteout("c.txt"), strings.NewReader(""), 0); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Upload whiteout: got %v, want ErrInvalidPath", err)
+	}
+
+	// 重新上传后标记文件被删除
+	if err = adapter.Upload(ctx, "b.txt", strings.NewReader("new b"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, adapter, "b.txt"); got != "new b" {
+		t.Errorf("b.txt = %q after Upload", got)
+	}
+	if err = top.IsExist(ctx, whiteout("b.txt")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("whiteout of b.txt not removed: %v", err)
+	}
+
+	// 复制、移动下层的文件写入最上层
+	if err = adapter.(Mover).Move(ctx, "c.txt", "d.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, top, "d.txt"); got != "c" {
+		t.Errorf("top d.txt = %q after Move", got)
+	}
+	if err = adapter.IsExist(ctx, "c.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("IsExist(c.txt) after Move: got %v, want ErrNotExist", err)
+	}
+
+	// 移动到自身时不删除文件
+	if err = adapter.(Mover).Move(ctx, "d.txt", "/d.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, adapter, "d.txt"); got != "c" {
+		t.Errorf("d.txt = %q after Move onto itself", got)
+	}
+
+	files, err := adapter.Lists(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fileNames(files), []string{"b.txt", "d.txt", "dir/bottom.txt", "dir/top.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lists = %v, want %v", got, want)
+	}
+	page, err := adapter.(PageLister).ListPage(ctx, "", "", 100)
+	if err != nil || !reflect.DeepEqual(page.Files, files) {
+		t.Errorf("ListPage = %v, %v, want %v", fileNames(page.Files), err, fileNames(files))
+	}
+	files, err = adapter.(DirLister).ListDir(ctx, "")
+	if got, want := fileNames(files), []string{"b.txt", "d.txt", "dir/"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir = %v, %v, want %v", got, err, want)
+	}
+}
@@ -3,11 +3,11 @@ package filesys
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/gogf/gf/v2/errors/gerror"
 	"github.com/gogf/gf/v2/net/gclient"
-	"github.com/gogf/gf/v2/os/gctx"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
@@ -61,6 +61,11 @@ func NewAdapterQiniu(i interface{}) (Adapter, error) {
 	return q, err
 }
 
+// Bucket 存储桶名称
+func (q *QiniuAdapter) Bucket() string {
+	return q.config.Bucket
+}
+
 func (q *QiniuAdapter) IsExist(ctx context.Context, object string) (err error) {
 	_, err = q.GetInfo(ctx, object)
 	return
@@ -87,7 +92,67 @@ func (q *QiniuAdapter) Upload(ctx context.Context, path string, reader io.Reader
 	path = objectRel(path)
 	// 需要先删除，文件已存在的话，没法覆盖
 	q.Delete(ctx, path)
-	err = form.Put(context.Background(), ret, token, path, reader, size, extra)
+	err = form.Put(ctx, ret, token, path, reader, size, extra)
+	return qiniuError(err)
+}
+
+func (q *QiniuAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	uploader, token, upHost, err := q.resumeUploader(object)
+	if err != nil {
+		return
+	}
+	ret := &storage.InitPartsRet{}
+	err = uploader.InitParts(ctx, token, upHost, q.config.Bucket, objectRel(object), true, ret)
+	if err != nil {
+		return "", qiniuError(err)
+	}
+	return ret.UploadID, nil
+}
+
+func (q *QiniuAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	uploader, token, upHost, err := q.resumeUploader(object)
+	if err != nil {
+		return
+	}
+	ret := &storage.UploadPartsRet{}
+	err = uploader.UploadParts(ctx, token, upHost, q.config.Bucket, objectRel(object), true, uploadID, int64(number), "", ret, reader, int(size))
+	if err != nil {
+		return nil, qiniuError(err)
+	}
+	return &Part{Number: number, ETag: ret.Etag, Size: size}, nil
+}
+
+func (q *QiniuAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	uploader, token, upHost, err := q.resumeUploader(object)
+	if err != nil {
+		return
+	}
+	extra := &storage.RputV2Extra{}
+	for _, part := range parts {
+		extra.Progresses = append(extra.Progresses, storage.UploadPartInfo{Etag: part.ETag, PartNumber: int64(part.Number)})
+	}
+	err = uploader.CompleteParts(ctx, token, upHost, &storage.PutRet{}, q.config.Bucket, objectRel(object), true, uploadID, extra)
+	return qiniuError(err)
+}
+
+// AbortMultipart sdk没有提供取消分片上传的方法，这里直接调用接口
+func (q *QiniuAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	uploader, token, upHost, err := q.resumeUploader(object)
+	if err != nil {
+		return
+	}
+	reqURL := upHost + "/buckets/" + q.config.Bucket + "/objects/" + base64.URLEncoding.EncodeToString([]byte(objectRel(object))) + "/uploads/" + uploadID
+	header := http.Header{}
+	header.Set("Authorization", "UpToken "+token)
+	return qiniuError(uploader.Client.CallWith(ctx, nil, http.MethodDelete, reqURL, header, nil, 0))
+}
+
+// resumeUploader 分片上传需要的上传对象、凭证和上传域名，凭证指定了文件名，允许覆盖已存在的文件
+func (q *QiniuAdapter) resumeUploader(object string) (uploader *storage.ResumeUploaderV2, token, upHost string, err error) {
+	policy := storage.PutPolicy{Scope: q.config.Bucket + ":" + objectRel(object), DetectMime: 1}
+	token = policy.UploadToken(q.mac)
+	uploader = storage.NewResumeUploaderV2(&storage.Config{Zone: q.zone})
+	upHost, err = uploader.UpHost(q.config.AccessKey, q.config.Bucket)
 	return
 }
 
@@ -115,21 +180,16 @@ func (q *QiniuAdapter) Delete(ctx context.Context, objects ...string) (err error
 	var res []storage.BatchOpRet
 	res, err = manager.Batch(deleteOps)
 	if err != nil {
-		return
+		return qiniuError(err)
 	}
 
-	var errs []string
+	var errs []error
 	for _, item := range res {
 		if item.Code != http.StatusOK {
-			errs = append(errs, fmt.Errorf("%+v: %v", item.Data, item.Code).Error())
+			errs = append(errs, qiniuError(&storage.ErrorInfo{Code: item.Code, Err: fmt.Sprintf("%s: %d", item.Data.Error, item.Code)}))
 		}
 	}
-
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
-	}
-
-	return
+	return joinErrors(errs...)
 }
 
 func (q *QiniuAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
@@ -154,21 +214,83 @@ func (q *QiniuAdapter) GetSignURL(ctx context.Context, object string, expire ...
 	return
 }
 
+// GetUploadSignURL 七牛只支持表单上传，生成指定文件名的上传凭证
+func (q *QiniuAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	policy, err := q.PostPolicy(ctx, &PostPolicyOptions{
+		Key:         object,
+		Expire:      expire,
+		ContentType: opts.ContentType,
+		MaxSize:     opts.MaxSize,
+	})
+	if err != nil {
+		return
+	}
+	return postUploadSign(policy), nil
+}
+
+// PostPolicy 生成表单上传凭证，只限制前缀时按上传的文件名保存
+// https://developer.qiniu.com/kodo/1208/upload-token
+func (q *QiniuAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	exp := uploadExpire(opts.Expire, q.config.Expire)
+	putPolicy := storage.PutPolicy{
+		Scope:      q.config.Bucket,
+		Expires:    uint64(exp),
+		FsizeMin:   opts.MinSize,
+		FsizeLimit: opts.MaxSize,
+		MimeLimit:  opts.ContentType,
+	}
+	fields := map[string]string{}
+	if opts.Key != "" {
+		key := objectRel(opts.Key)
+		putPolicy.Scope += ":" + key
+		fields["key"] = key
+	} else if prefix := objectRel(opts.KeyPrefix); prefix != "" {
+		putPolicy.Scope += ":" + prefix
+		putPolicy.IsPrefixalScope = 1
+		putPolicy.SaveKey = prefix + "$(fname)"
+	}
+	upHost, err := storage.NewFormUploader(&storage.Config{Zone: q.zone, UseHTTPS: true}).UpHost(q.config.AccessKey, q.config.Bucket)
+	if err != nil {
+		return nil, qiniuError(err)
+	}
+	fields["token"] = putPolicy.UploadToken(q.mac)
+	return &PostPolicyResult{
+		URL:    upHost,
+		Fields: fields,
+		Expire: time.Now().Add(time.Duration(exp) * time.Second),
+	}, nil
+}
+
 func (q *QiniuAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return q.download(ctx, object, nil)
+}
+
+func (q *QiniuAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	return q.download(ctx, object, map[string]string{"Range": httpRange(offset, length)})
+}
+
+func (q *QiniuAdapter) download(ctx context.Context, object string, header map[string]string) (body io.ReadCloser, err error) {
 	link, err := q.GetSignURL(ctx, object)
 	if err != nil {
 		return
 	}
-	req := gclient.New().SetTimeout(30 * time.Minute)
+	req := gclient.New().SetTimeout(30 * time.Minute).Header(header)
 	if strings.HasPrefix(strings.ToLower(link), "https://") {
 		req.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
 	}
-	resp, err := req.Get(gctx.New(), link)
+	resp, err := req.Get(ctx, link)
 	if err != nil {
 		return
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, gerror.New("下载文件失败")
+		resp.Close()
+		return nil, statusError(resp.StatusCode, gerror.New("下载文件失败"))
 	}
 	body = resp.Body
 	return
@@ -180,40 +302,91 @@ func (q *QiniuAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	object = objectRel(object)
 	fileInfo, err = q.bucketManager.Stat(q.config.Bucket, object)
 	if err != nil {
-		return
+		return nil, qiniuError(err)
 	}
 	info = &File{
 		Name:    object,
 		Size:    fileInfo.Fsize,
 		ModTime: storage.ParsePutTime(fileInfo.PutTime),
-		IsDir:   fileInfo.Fsize == 0,
+		IsDir:   isDirKey(object),
 	}
 	return
 }
 
+func (q *QiniuAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	err = q.bucketManager.Copy(q.config.Bucket, objectRel(src), q.config.Bucket, objectRel(dst), true)
+	return qiniuError(err)
+}
+
+func (q *QiniuAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	err = q.bucketManager.Move(q.config.Bucket, objectRel(src), q.config.Bucket, objectRel(dst), true)
+	return qiniuError(err)
+}
+
 func (q *QiniuAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	var items []storage.ListItem
+	return listAll(ctx, q, prefix)
+}
 
-	prefix = objectRel(prefix)
-	limit := 1000
-	cfg := &storage.Config{
-		Zone: q.zone,
+func (q *QiniuAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
 	}
+	return q.list(ctx, prefix, "", token, limit)
+}
 
-	manager := storage.NewBucketManager(q.mac, cfg)
-	items, _, _, _, err = manager.ListFiles(q.config.Bucket, prefix, "", "", limit)
+func (q *QiniuAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, q.list)
+}
+
+func (q *QiniuAdapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
+	var (
+		items          []storage.ListItem
+		commonPrefixes []string
+		marker         string
+		hasNext        bool
+	)
+
+	// 七牛云每页最多返回1000条
+	if limit > 1000 {
+		limit = 1000
+	}
+	items, commonPrefixes, marker, hasNext, err = q.bucketManager.ListFiles(q.config.Bucket, objectRel(prefix), delimiter, token, limit)
 	if err != nil {
-		return
+		return nil, qiniuError(err)
 	}
 
+	result = &ListResult{}
 	for _, item := range items {
-		files = append(files, &File{
+		result.Files = append(result.Files, &File{
 			ModTime: storage.ParsePutTime(item.PutTime),
 			Name:    objectRel(item.Key),
 			Size:    item.Fsize,
-			IsDir:   item.Fsize == 0,
+			IsDir:   isDirKey(item.Key),
 		})
 	}
-
+	for _, commonPrefix := range commonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix))
+	}
+	if hasNext {
+		result.NextToken = marker
+	}
 	return
 }
+
+// qiniuError 对七牛云返回的错误进行归类
+// https://developer.qiniu.com/kodo/3928/error-responses
+func qiniuError(err error) error {
+	var info *storage.ErrorInfo
+	if !errors.As(err, &info) {
+		return err
+	}
+	switch info.Code {
+	case 612: // 指定资源不存在或已被删除
+		return wrapError(ErrNotExist, err)
+	case 614: // 目标资源已存在
+		return wrapError(ErrAlreadyExists, err)
+	case 631: // 指定空间不存在
+		return wrapError(ErrNotExist, err)
+	}
+	return statusError(info.Code, err)
+}
@@ -0,0 +1,382 @@
+package filesys
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/gvalid"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ConfigS3 兼容S3协议的存储，如 AWS S3、Ceph RGW、Cloudflare R2、Wasabi、SeaweedFS、MinIO
+type ConfigS3 struct {
+	AccessKey       string `json:"accessKey" v:"required#AccessKey不能为空"`
+	SecretKey       string `json:"secretKey" v:"required#SecretKey不能为空"`
+	SessionToken    string `json:"sessionToken"` // 临时凭证的 SessionToken
+	Endpoint        string `json:"endpoint" v:"required#Endpoint不能为空"`
+	Region          string `json:"region"` // 区域，为空时自动获取
+	Bucket          string `json:"bucket" v:"required#Bucket不能为空"`
+	AddressingStyle string `json:"addressingStyle"` // 访问存储桶的方式，path 或 virtual，为空时根据 Endpoint 自动选择
+	DisableSSL      bool   `json:"disableSSL"`      // 不使用 https 访问 Endpoint
+	Domain          string `json:"domain"`
+	Expire          int64  `json:"expire"`
+}
+
+type S3Adapter struct {
+	config *ConfigS3
+	client *minio.Client
+}
+
+func NewAdapterS3(i interface{}) (Adapter, error) {
+	cfg := (*ConfigS3)(nil)
+	if err := gconv.Scan(i, &cfg); err != nil {
+		return nil, err
+	}
+	if verr := gvalid.New().Data(&cfg).Run(context.Background()); verr != nil {
+		if err := verr.FirstError(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Endpoint 带协议时以协议为准
+	if strings.HasPrefix(cfg.Endpoint, "http://") {
+		cfg.DisableSSL = true
+	}
+	cfg.Endpoint = strings.TrimRight(strings.TrimPrefix(strings.TrimPrefix(cfg.Endpoint, "http://"), "https://"), "/")
+
+	lookup := minio.BucketLookupAuto
+	switch strings.ToLower(cfg.AddressingStyle) {
+	case "":
+	case "path":
+		lookup = minio.BucketLookupPath
+	case "virtual":
+		lookup = minio.BucketLookupDNS
+	default:
+		return nil, gerror.Newf("不支持的存储桶访问方式[%s]", cfg.AddressingStyle)
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken),
+		Secure:       !cfg.DisableSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Domain == "" {
+		cfg.Domain = client.EndpointURL().String()
+		if lookup == minio.BucketLookupPath || (lookup == minio.BucketLookupAuto && !strings.Contains(cfg.Endpoint, "amazonaws.com")) {
+			cfg.Domain += "/" + cfg.Bucket
+		} else {
+			cfg.Domain = strings.Replace(cfg.Domain, "://", "://"+cfg.Bucket+".", 1)
+		}
+	}
+	cfg.Domain = strings.TrimRight(cfg.Domain, "/")
+
+	return &S3Adapter{
+		config: cfg,
+		client: client,
+	}, nil
+}
+
+// Bucket 存储桶名称
+func (s *S3Adapter) Bucket() string {
+	return s.config.Bucket
+}
+
+func (s *S3Adapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = s.GetInfo(ctx, object)
+	return
+}
+
+func (s *S3Adapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	_, err = s.client.PutObject(ctx, s.config.Bucket, objectRel(path), reader, size, s3PutOptions(headers...))
+	return s3Error(err)
+}
+
+func (s *S3Adapter) Delete(ctx context.Context, objects ...string) (err error) {
+	if len(objects) == 0 {
+		return
+	}
+
+	var errs []error
+
+	objectsChan := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsChan)
+		for _, object := range objects {
+			objectsChan <- minio.ObjectInfo{Key: objectRel(object)}
+		}
+	}()
+	for errRm := range s.client.RemoveObjects(ctx, s.config.Bucket, objectsChan, minio.RemoveObjectsOptions{}) {
+		errs = append(errs, s3Error(errRm.Err))
+	}
+	return joinErrors(errs...)
+}
+
+func (s *S3Adapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	exp := s.config.Expire
+	if len(expire) > 0 {
+		exp = expire[0]
+	}
+	if exp <= 0 {
+		link = s.config.Domain + objectAbs(object)
+		return
+	}
+	if exp > sevenDays {
+		exp = sevenDays
+	}
+	var u *url.URL
+	u, err = s.client.PresignedGetObject(ctx, s.config.Bucket, objectRel(object), time.Duration(exp)*time.Second, nil)
+	if err != nil {
+		return "", s3Error(err)
+	}
+	return u.String(), nil
+}
+
+// GetUploadSignURL 生成 PUT 上传链接，ContentType 不参与签名
+func (s *S3Adapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	var u *url.URL
+	exp := uploadExpire(expire, s.config.Expire)
+	if exp > sevenDays {
+		exp = sevenDays
+	}
+	u, err = s.client.PresignedPutObject(ctx, s.config.Bucket, objectRel(object), time.Duration(exp)*time.Second)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return newUploadSign(u.String(), exp, opts), nil
+}
+
+func (s *S3Adapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	var (
+		u      *url.URL
+		fields map[string]string
+	)
+	exp := uploadExpire(opts.Expire, s.config.Expire)
+	if exp > sevenDays {
+		exp = sevenDays
+	}
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+
+	p := minio.NewPostPolicy()
+	if err = p.SetBucket(s.config.Bucket); err != nil {
+		return
+	}
+	if opts.Key != "" {
+		err = p.SetKey(objectRel(opts.Key))
+	} else {
+		err = p.SetKeyStartsWith(objectRel(opts.KeyPrefix))
+	}
+	if err != nil {
+		return
+	}
+	if err = p.SetExpires(deadline); err != nil {
+		return
+	}
+	if opts.ContentType != "" {
+		if err = p.SetContentType(opts.ContentType); err != nil {
+			return
+		}
+	}
+	if opts.MaxSize > 0 {
+		if err = p.SetContentLengthRange(opts.MinSize, opts.MaxSize); err != nil {
+			return
+		}
+	}
+	u, fields, err = s.client.PresignedPostPolicy(ctx, p)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	fields["key"] = postPolicyKey(opts)
+	return &PostPolicyResult{
+		URL:    u.String(),
+		Fields: fields,
+		Expire: deadline,
+	}, nil
+}
+
+func (s *S3Adapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return s.DownloadRange(ctx, object, 0, 0)
+}
+
+func (s *S3Adapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	opts := minio.GetObjectOptions{}
+	if length > 0 {
+		err = opts.SetRange(offset, offset+length-1)
+	} else if offset > 0 {
+		err = opts.SetRange(offset, 0)
+	}
+	if err != nil {
+		return
+	}
+	// Client.GetObject 的 Stat 会去掉 Range，使用 Core.GetObject 立即发起请求，文件不存在等错误也能及时返回
+	core := minio.Core{Client: s.client}
+	body, _, _, err = core.GetObject(ctx, s.config.Bucket, objectRel(object), opts)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return
+}
+
+func (s *S3Adapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	var objInfo minio.ObjectInfo
+	object = objectRel(object)
+	objInfo, err = s.client.StatObject(ctx, s.config.Bucket, object, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	info = &File{
+		ModTime: objInfo.LastModified,
+		Name:    object,
+		Size:    objInfo.Size,
+		IsDir:   isDirKey(object),
+		Header:  make(map[string]string),
+	}
+	for k := range objInfo.Metadata {
+		info.Header[k] = objInfo.Metadata.Get(k)
+	}
+	return
+}
+
+func (s *S3Adapter) Copy(ctx context.Context, src, dst string) (err error) {
+	_, err = s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.config.Bucket, Object: objectRel(dst)},
+		minio.CopySrcOptions{Bucket: s.config.Bucket, Object: objectRel(src)},
+	)
+	return s3Error(err)
+}
+
+func (s *S3Adapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	core := minio.Core{Client: s.client}
+	uploadID, err = core.NewMultipartUpload(ctx, s.config.Bucket, objectRel(object), s3PutOptions(headers))
+	return uploadID, s3Error(err)
+}
+
+func (s *S3Adapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	var res minio.ObjectPart
+	core := minio.Core{Client: s.client}
+	res, err = core.PutObjectPart(ctx, s.config.Bucket, objectRel(object), uploadID, number, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return &Part{Number: res.PartNumber, ETag: res.ETag, Size: size}, nil
+}
+
+func (s *S3Adapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	core := minio.Core{Client: s.client}
+	_, err = core.CompleteMultipartUpload(ctx, s.config.Bucket, objectRel(object), uploadID, completeParts, minio.PutObjectOptions{})
+	return s3Error(err)
+}
+
+func (s *S3Adapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	core := minio.Core{Client: s.client}
+	return s3Error(core.AbortMultipartUpload(ctx, s.config.Bucket, objectRel(object), uploadID))
+}
+
+func (s *S3Adapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, s, prefix)
+}
+
+func (s *S3Adapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return s.list(ctx, prefix, "", token, limit)
+}
+
+func (s *S3Adapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDirPages(ctx, prefix, s.list)
+}
+
+func (s *S3Adapter) list(ctx context.Context, prefix, delimiter, token string, limit int) (result *ListResult, err error) {
+	var res minio.ListBucketV2Result
+	core := minio.Core{Client: s.client}
+	res, err = core.ListObjectsV2(s.config.Bucket, objectRel(prefix), "", token, delimiter, limit)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	result = &ListResult{}
+	for _, object := range res.Contents {
+		file := &File{
+			ModTime: object.LastModified,
+			Size:    object.Size,
+			IsDir:   isDirKey(object.Key),
+			Name:    objectRel(object.Key),
+			Header:  make(map[string]string),
+		}
+		if object.ContentType != "" {
+			file.Header["Content-Type"] = object.ContentType
+		}
+		result.Files = append(result.Files, file)
+	}
+	for _, commonPrefix := range res.CommonPrefixes {
+		result.Files = append(result.Files, dirFile(commonPrefix.Prefix))
+	}
+	if res.IsTruncated {
+		result.NextToken = res.NextContinuationToken
+	}
+	return
+}
+
+// s3PutOptions 把header转换为S3的上传参数
+func s3PutOptions(headers ...map[string]string) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		UserMetadata: make(map[string]string),
+	}
+
+	for _, header := range headers {
+		for k, v := range header {
+			switch strings.ToLower(k) {
+			case "cache-control":
+				opts.CacheControl = v
+			case "content-disposition":
+				opts.ContentDisposition = v
+			case "content-encoding":
+				opts.ContentEncoding = v
+			case "content-language":
+				opts.ContentLanguage = v
+			case "content-type":
+				opts.ContentType = v
+			default:
+				opts.UserMetadata[k] = v
+			}
+		}
+	}
+	return opts
+}
+
+// s3Error 对S3返回的错误进行归类，批量删除中单个文件的错误只有错误码
+func s3Error(err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.StatusCode != 0 {
+		return statusError(resp.StatusCode, err)
+	}
+	switch resp.Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return wrapError(ErrNotExist, err)
+	case "AccessDenied":
+		return wrapError(ErrPermission, err)
+	case "InternalError", "ServiceUnavailable", "RequestTimeout", "SlowDown":
+		return temporaryError(err)
+	}
+	return err
+}
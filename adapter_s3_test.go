@@ -0,0 +1,556 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const s3TestBucket = "bucket"
+
+// s3TestServer 只实现测试用到的 S3 接口，检查每个请求的存储桶访问方式
+type s3TestServer struct {
+	t       *testing.T
+	virtual bool // 是否要求 virtual-host 方式访问存储桶
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	server  *httptest.Server
+}
+
+// newS3TestServer 启动测试服务器，Endpoint 为 s3.test，全部连接都被转发到测试服务器
+func newS3TestServer(t *testing.T, virtual bool) *s3TestServer {
+	s := &s3TestServer{t: t, virtual: virtual, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	s.server = httptest.NewTLSServer(s)
+	t.Cleanup(s.server.Close)
+
+	defaultTransport := minio.DefaultTransport
+	minio.DefaultTransport = func(secure bool) (*http.Transport, error) {
+		return &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, s.server.Listener.Addr().String())
+			},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}, nil
+	}
+	t.Cleanup(func() {
+		minio.DefaultTransport = defaultTransport
+	})
+	return s
+}
+
+func (s *s3TestServer) adapter(style string) *S3Adapter {
+	adapter, err := NewAdapterS3(ConfigS3{
+		AccessKey:       "access",
+		SecretKey:       "secret",
+		Endpoint:        "https://s3.test",
+		Region:          "us-east-1",
+		Bucket:          s3TestBucket,
+		AddressingStyle: style,
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return adapter.(*S3Adapter)
+}
+
+type s3ErrorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+func (s *s3TestServer) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(s3ErrorResponse{Code: code, Message: code})
+}
+
+func (s *s3TestServer) writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func s3ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *s3TestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 检查存储桶的访问方式，并取出对象的 key
+	host := strings.Split(r.Host, ":")[0]
+	var key string
+	if s.virtual {
+		if host != s3TestBucket+".s3.test" {
+			s.t.Errorf("%s %s: host %s, want virtual-host style", r.Method, r.URL, r.Host)
+		}
+		key = strings.TrimPrefix(r.URL.Path, "/")
+	} else {
+		if host != "s3.test" || (r.URL.Path != "/"+s3TestBucket && !strings.HasPrefix(r.URL.Path, "/"+s3TestBucket+"/")) {
+			s.t.Errorf("%s %s: host %s, want path style", r.Method, r.URL, r.Host)
+		}
+		key = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+s3TestBucket), "/")
+	}
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		s.list(w, query)
+	case r.Method == http.MethodPost && key == "" && query.Has("delete"):
+		s.deleteObjects(w, r)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[uploadID] = map[int][]byte{}
+		s.writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: s3TestBucket, Key: key, UploadId: uploadID})
+	case r.Method == http.MethodPut && uploadID != "":
+		parts, ok := s.uploads[uploadID]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		parts[number] = data
+		w.Header().Set("ETag", s3ETag(data))
+	case r.Method == http.MethodPost && uploadID != "":
+		s.completeMultipart(w, r, key, uploadID)
+	case r.Method == http.MethodDelete && uploadID != "":
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[key] = data
+		w.Header().Set("ETag", s3ETag(data))
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		s.get(w, r, key)
+	default:
+		s.writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *s3TestServer) get(w http.ResponseWriter, r *http.Request, key string) {
+	data, ok := s.objects[key]
+	if !ok {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	w.Header().Set("ETag", s3ETag(data))
+	w.Header().Set("Last-Modified", time.Unix(1700000000, 0).UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	status := http.StatusOK
+	if spec := r.Header.Get("Range"); spec != "" {
+		var start, end int
+		spec = strings.TrimPrefix(spec, "bytes=")
+		if strings.HasSuffix(spec, "-") {
+			start, _ = strconv.Atoi(strings.TrimSuffix(spec, "-"))
+			end = len(data) - 1
+		} else {
+			fmt.Sscanf(spec, "%d-%d", &start, &end)
+		}
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+		if start >= len(data) {
+			s.writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data, status = data[start:end+1], http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// list 分页标记使用 base64 编码的 key，检查适配器原样传回服务端的标记
+func (s *s3TestServer) list(w http.ResponseWriter, query map[string][]string) {
+	get := func(name string) string {
+		if values := query[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	prefix, delimiter := get("prefix"), get("delimiter")
+	maxKeys, _ := strconv.Atoi(get("max-keys"))
+	after := ""
+	if token := get("continuation-token"); token != "" {
+		data, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			s.t.Errorf("invalid continuation token %q", token)
+		}
+		after = string(data)
+	}
+
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{Name: s3TestBucket, Prefix: prefix, MaxKeys: maxKeys}
+
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	last := ""
+	for _, key := range keys {
+		if delimiter != "" {
+			if idx := strings.Index(key[len(prefix):], delimiter); idx >= 0 {
+				dir := key[:len(prefix)+idx+len(delimiter)]
+				if strings.HasPrefix(after, dir) || (last != "" && strings.HasPrefix(last, dir)) {
+					continue
+				}
+				if result.KeyCount >= maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: dir})
+				result.KeyCount++
+				// 跳过该目录下的其他文件
+				last = dir + "\xff"
+				continue
+			}
+		}
+		if result.KeyCount >= maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: time.Unix(1700000000, 0).UTC().Format(time.RFC3339),
+			ETag:         s3ETag(s.objects[key]),
+			Size:         len(s.objects[key]),
+		})
+		result.KeyCount++
+		last = key
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+	}
+	s.writeXML(w, result)
+}
+
+func (s *s3TestServer) completeMultipart(w http.ResponseWriter, r *http.Request, key, uploadID string) {
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var complete struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+		s.writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var buf bytes.Buffer
+	for _, part := range complete.Parts {
+		data, ok := parts[part.PartNumber]
+		if !ok || s3ETag(data) != `"`+strings.Trim(part.ETag, `"`)+`"` {
+			s.writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		buf.Write(data)
+	}
+	s.objects[key] = buf.Bytes()
+	delete(s.uploads, uploadID)
+	s.writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: s3TestBucket, Key: key, ETag: s3ETag(buf.Bytes())})
+}
+
+// deleteObjects 批量删除，以 denied/ 开头的文件没有删除权限
+func (s *s3TestServer) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	type deleted struct {
+		Key string
+	}
+	type deleteError struct {
+		Key     string
+		Code    string
+		Message string
+	}
+	result := struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}{}
+	for _, object := range request.Objects {
+		if strings.HasPrefix(object.Key, "denied/") {
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: "AccessDenied", Message: "Access Denied"})
+			continue
+		}
+		delete(s.objects, object.Key)
+		result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+	}
+	s.writeXML(w, result)
+}
+
+func TestS3AdapterAddressingStyle(t *testing.T) {
+	ctx := context.Background()
+	for _, style := range []string{"path", "virtual", ""} {
+		server := newS3TestServer(t, style == "virtual")
+		adapter := server.adapter(style)
+		if err := adapter.Upload(ctx, "/dir/a.txt", strings.NewReader("hello"), 5, map[string]string{"Content-Type": "text/plain"}); err != nil {
+			t.Fatalf("%s: Upload: %v", style, err)
+		}
+		if string(server.objects["dir/a.txt"]) != "hello" {
+			t.Fatalf("%s: objects = %v", style, server.objects)
+		}
+		if got := readObject(t, adapter, "dir/a.txt"); got != "hello" {
+			t.Errorf("%s: Download = %q", style, got)
+		}
+		info, err := adapter.GetInfo(ctx, "dir/a.txt")
+		if err != nil || info.Size != 5 || info.Name != "dir/a.txt" {
+			t.Errorf("%s: GetInfo = %+v, %v", style, info, err)
+		}
+		if _, err = adapter.GetInfo(ctx, "missing.txt"); !errors.Is(err, ErrNotExist) {
+			t.Errorf("%s: GetInfo missing file: got %v, want ErrNotExist", style, err)
+		}
+
+		// 公开链接与访问方式一致
+		link, err := adapter.GetSignURL(ctx, "dir/a.txt", 0)
+		want := "https://s3.test/bucket/dir/a.txt"
+		if style == "virtual" {
+			want = "https://bucket.s3.test/dir/a.txt"
+		}
+		if err != nil || link != want {
+			t.Errorf("%s: GetSignURL = %s, %v, want %s", style, link, err, want)
+		}
+	}
+}
+
+func TestS3AdapterDownloadRange(t *testing.T) {
+	server := newS3TestServer(t, false)
+	adapter := server.adapter("path")
+	server.objects["a.txt"] = []byte("0123456789")
+	ctx := context.Background()
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 0, "0123456789"},
+		{3, 4, "3456"},
+		{7, 0, "789"},
+		{8, 100, "89"},
+	}
+	for _, tt := range tests {
+		body, err := adapter.DownloadRange(ctx, "a.txt", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("DownloadRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || string(data) != tt.want {
+			t.Errorf("DownloadRange(%d, %d) = %q, %v, want %q", tt.offset, tt.length, data, err, tt.want)
+		}
+	}
+	if _, err := adapter.DownloadRange(ctx, "missing.txt", 1, 2); !errors.Is(err, ErrNotExist) {
+		t.Errorf("DownloadRange missing file: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestS3AdapterMultipart(t *testing.T) {
+	server := newS3TestServer(t, false)
+	adapter := server.adapter("path")
+	ctx := context.Background()
+
+	uploadID, err := adapter.InitMultipart(ctx, "big.bin", map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []*Part
+	for i, content := range []string{"part-1|", "part-2|", "part-3"} {
+		part, err := adapter.UploadPart(ctx, "big.bin", uploadID, i+1, strings.NewReader(content), int64(len(content)))
+		if err != nil {
+			t.Fatalf("UploadPart(%d): %v", i+1, err)
+		}
+		if part.Number != i+1 || part.Size != int64(len(content)) || part.ETag == "" {
+			t.Fatalf("UploadPart(%d) = %+v", i+1, part)
+		}
+		parts = append(parts, part)
+	}
+	if err = adapter.CompleteMultipart(ctx, "big.bin", uploadID, parts); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(server.objects["big.bin"]); got != "part-1|part-2|part-3" {
+		t.Errorf("big.bin = %q", got)
+	}
+
+	// 取消后分片上传不存在
+	if uploadID, err = adapter.InitMultipart(ctx, "aborted.bin", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = adapter.AbortMultipart(ctx, "aborted.bin", uploadID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = adapter.UploadPart(ctx, "aborted.bin", uploadID, 1, strings.NewReader("x"), 1); !errors.Is(err, ErrNotExist) {
+		t.Errorf("UploadPart after AbortMultipart: got %v, want ErrNotExist", err)
+	}
+
+	// 通过存储器分片上传
+	store := NewWithAdapter(adapter)
+	store.SetMultipartConfig(MultipartConfig{PartSize: 5 << 20, Threshold: 5 << 20})
+	data := testData(11 << 20)
+	if err = store.Upload(ctx, "store.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(server.objects["store.bin"], data) || len(server.uploads) != 0 {
+		t.Errorf("store.bin has %d bytes, %d pending uploads", len(server.objects["store.bin"]), len(server.uploads))
+	}
+}
+
+func TestS3AdapterListPage(t *testing.T) {
+	server := newS3TestServer(t, false)
+	adapter := server.adapter("path")
+	for _, key := range []string{"a/1", "a/2", "a/b/3", "a/b/4", "c", "d/5"} {
+		server.objects[key] = []byte(key)
+	}
+	ctx := context.Background()
+
+	var (
+		pages [][]string
+		token string
+	)
+	for len(pages) < 10 {
+		result, err := adapter.ListPage(ctx, "", token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, fileNames(result.Files))
+		if token = result.NextToken; token == "" {
+			break
+		}
+	}
+	want := [][]string{{"a/1", "a/2"}, {"a/b/3", "a/b/4"}, {"c", "d/5"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("ListPage pages = %v, want %v", pages, want)
+	}
+
+	files, err := adapter.ListDir(ctx, "a")
+	if got, want := fileNames(files), []string{"a/1", "a/2", "a/b/"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir(a) = %v, %v, want %v", got, err, want)
+	}
+	files, err = adapter.Lists(ctx, "a/b")
+	if got, want := fileNames(files), []string{"a/b/3", "a/b/4"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Lists(a/b) = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestS3AdapterDelete(t *testing.T) {
+	server := newS3TestServer(t, false)
+	adapter := server.adapter("path")
+	server.objects["a.txt"] = []byte("a")
+	server.objects["denied/b.txt"] = []byte("b")
+	err := adapter.Delete(context.Background(), "a.txt", "denied/b.txt")
+	if !errors.Is(err, ErrPermission) {
+		t.Fatalf("Delete = %v, want ErrPermission", err)
+	}
+	if _, ok := server.objects["a.txt"]; ok {
+		t.Error("a.txt not deleted")
+	}
+}
+
+func TestS3AdapterPostPolicy(t *testing.T) {
+	adapter := newS3TestServer(t, false).adapter("path")
+	for _, opts := range []*PostPolicyOptions{nil, {KeyPrefix: "upload/"}} {
+		policy, err := adapter.PostPolicy(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("PostPolicy(%+v): %v", opts, err)
+		}
+		if opts == nil {
+			opts = &PostPolicyOptions{}
+		}
+		if want := objectRel(opts.KeyPrefix) + "${filename}"; policy.Fields["key"] != want {
+			t.Errorf("PostPolicy(%+v) key = %s, want %s", opts, policy.Fields["key"], want)
+		}
+		data, err := base64.StdEncoding.DecodeString(policy.Fields["policy"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 没有前缀时允许任意文件名
+		if want := `["starts-with","$key","` + opts.KeyPrefix + `"]`; !strings.Contains(string(data), want) {
+			t.Errorf("PostPolicy(%+v) policy = %s, want condition %s", opts, data, want)
+		}
+	}
+}
+
+func TestS3AdapterGetUploadSignURL(t *testing.T) {
+	adapter := newS3TestServer(t, false).adapter("path")
+	// 直接调用适配器时 opts 可以为 nil
+	sign, err := adapter.GetUploadSignURL(context.Background(), "/dir/a.txt", 60, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sign.Method != http.MethodPut || !strings.HasPrefix(sign.URL, "https://s3.test/bucket/dir/a.txt?") || len(sign.Headers) != 0 {
+		t.Errorf("GetUploadSignURL = %+v", sign)
+	}
+	if sign, err = adapter.GetUploadSignURL(context.Background(), "a.txt", 60, &UploadSignOptions{ContentType: "text/plain"}); err != nil || sign.Headers["Content-Type"] != "text/plain" {
+		t.Errorf("GetUploadSignURL with ContentType = %+v, %v", sign, err)
+	}
+}
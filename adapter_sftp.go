@@ -0,0 +1,453 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+	"github.com/gogf/gf/v2/util/gvalid"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type ConfigSftp struct {
+	Host                  string `json:"host" v:"required#Host不能为空"`
+	Port                  int    `json:"port"`
+	Username              string `json:"username" v:"required#Username不能为空"`
+	Password              string `json:"password"`
+	PrivateKey            string `json:"privateKey"`            // 私钥内容，PEM格式
+	PrivateKeyFile        string `json:"privateKeyFile"`        // 私钥文件路径
+	Passphrase            string `json:"passphrase"`            // 私钥密码
+	KnownHosts            string `json:"knownHosts"`            // known_hosts 文件路径，为空时使用 ~/.ssh/known_hosts
+	InsecureIgnoreHostKey bool   `json:"insecureIgnoreHostKey"` // 不校验服务器公钥，仅用于测试环境
+	Path                  string `json:"path" v:"required#Path不能为空"`
+	Domain                string `json:"domain" v:"required#Domain不能为空"`
+	Timeout               int64  `json:"timeout"` // 连接超时时间，单位秒
+}
+
+type SftpAdapter struct {
+	config    *ConfigSftp
+	sshConfig *ssh.ClientConfig
+	addr      string
+
+	mu        sync.Mutex
+	sshClient *ssh.Client
+	client    *sftp.Client
+}
+
+func NewAdapterSftp(i interface{}) (Adapter, error) {
+	cfg := (*ConfigSftp)(nil)
+	if err := gconv.Scan(i, &cfg); err != nil {
+		return nil, err
+	}
+	if verr := gvalid.New().Data(&cfg).Run(context.Background()); verr != nil {
+		if err := verr.FirstError(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = 22
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	cfg.Path = path.Clean("/" + strings.TrimSpace(cfg.Path))
+
+	auth, err := sftpAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := sftpHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SftpAdapter{
+		config: cfg,
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         time.Duration(cfg.Timeout) * time.Second,
+		},
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+	}, nil
+}
+
+// sftpAuth 根据配置生成登录方式，同时配置了密码和私钥时优先使用私钥
+func sftpAuth(cfg *ConfigSftp) (auth []ssh.AuthMethod, err error) {
+	key := []byte(cfg.PrivateKey)
+	if len(key) == 0 && cfg.PrivateKeyFile != "" {
+		if key, err = os.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return
+		}
+	}
+	if len(key) > 0 {
+		var signer ssh.Signer
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, gerror.Wrap(err, "私钥解析失败")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, gerror.New("Password和PrivateKey不能同时为空")
+	}
+	return
+}
+
+// sftpHostKeyCallback 根据 known_hosts 校验服务器公钥
+func sftpHostKeyCallback(cfg *ConfigSftp) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := cfg.KnownHosts
+	if file == "" {
+		home, err := gfile.Home()
+		if err != nil {
+			return nil, err
+		}
+		file = gfile.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, gerror.Wrapf(err, "读取known_hosts文件[%s]失败", file)
+	}
+	return callback, nil
+}
+
+// conn 获取sftp连接，连接断开后重新连接
+func (s *SftpAdapter) conn() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	sshClient, err := ssh.Dial("tcp", s.addr, s.sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	s.sshClient, s.client = sshClient, client
+	go func() {
+		// 连接断开时清理，下次使用时重新连接
+		sshClient.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.sshClient == sshClient {
+			s.client.Close()
+			s.sshClient, s.client = nil, nil
+		}
+	}()
+	return client, nil
+}
+
+// Close 关闭sftp连接
+func (s *SftpAdapter) Close() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sshClient == nil {
+		return
+	}
+	s.client.Close()
+	err = s.sshClient.Close()
+	s.sshClient, s.client = nil, nil
+	return
+}
+
+// fullPath 获取文件在服务器上的完整路径，不允许跳出存储目录
+func (s *SftpAdapter) fullPath(object string) (string, error) {
+	filePath := path.Join(s.config.Path, object)
+	if filePath != s.config.Path && !strings.HasPrefix(filePath, strings.TrimSuffix(s.config.Path, "/")+"/") {
+		return "", invalidPathError(object)
+	}
+	return filePath, nil
+}
+
+func (s *SftpAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = s.GetInfo(ctx, object)
+	return
+}
+
+func (s *SftpAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	savePath, err := s.fullPath(path)
+	if err != nil {
+		return
+	}
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	if err = client.MkdirAll(gfile.Dir(savePath)); err != nil {
+		return
+	}
+	file, err := client.Create(savePath)
+	if err != nil {
+		return
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	_, err = file.ReadFrom(reader)
+	// 服务器在关闭文件时才会报告部分写入错误，例如超出配额、磁盘已满
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	return
+}
+
+func (s *SftpAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	var errs []error
+	for _, object := range objects {
+		filePath, errPath := s.fullPath(object)
+		if errPath != nil {
+			errs = append(errs, errPath)
+			continue
+		}
+		if err = client.Remove(filePath); err != nil && !errors.Is(err, ErrNotExist) {
+			errs = append(errs, gerror.Wrapf(err, "删除文件[%s]失败", object))
+		}
+	}
+	return joinErrors(errs...)
+}
+
+func (s *SftpAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	link = gfile.Join(s.config.Domain, object)
+	return
+}
+
+func (s *SftpAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return s.DownloadRange(ctx, object, 0, 0)
+}
+
+func (s *SftpAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	filePath, err := s.fullPath(object)
+	if err != nil {
+		return
+	}
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	file, err := client.Open(filePath)
+	if err != nil {
+		return
+	}
+	if offset > 0 {
+		if _, err = file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return
+		}
+	}
+	return limitBody(file, length), nil
+}
+
+func (s *SftpAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	filePath, err := s.fullPath(object)
+	if err != nil {
+		return
+	}
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	fileInfo, err := client.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, notExistError(object)
+		}
+		return
+	}
+	return &File{
+		ModTime: fileInfo.ModTime(),
+		Name:    objectRel(object),
+		Size:    fileInfo.Size(),
+		IsDir:   fileInfo.IsDir(),
+		Header:  map[string]string{},
+	}, nil
+}
+
+func (s *SftpAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	srcPath, err := s.fullPath(src)
+	if err != nil {
+		return
+	}
+	dstPath, err := s.fullPath(dst)
+	if err != nil {
+		return
+	}
+	if srcPath == dstPath {
+		return s.IsExist(ctx, src)
+	}
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	if err = client.MkdirAll(gfile.Dir(dstPath)); err != nil {
+		return
+	}
+	// 标准的 rename 不允许覆盖已存在的文件，优先使用 posix-rename 扩展
+	if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+		return client.PosixRename(srcPath, dstPath)
+	}
+	if _, err = client.Stat(srcPath); err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return notExistError(src)
+		}
+		return
+	}
+	if _, err = client.Stat(dstPath); errors.Is(err, ErrNotExist) {
+		return client.Rename(srcPath, dstPath)
+	}
+	// 目标文件已存在时先改名备份，移动成功后再删除，失败时恢复，避免移动失败导致目标文件丢失
+	backup := path.Join(path.Dir(dstPath), ".filesys-move-"+guid.S())
+	if err = client.Rename(dstPath, backup); err != nil {
+		return
+	}
+	if err = client.Rename(srcPath, dstPath); err != nil {
+		client.Rename(backup, dstPath)
+		return
+	}
+	client.Remove(backup)
+	return nil
+}
+
+func (s *SftpAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, s, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (s *SftpAdapter) pathOrder() {}
+
+// ListPage 按目录层级顺序遍历，token 为上一页最后一个文件的相对路径
+func (s *SftpAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	result = &ListResult{}
+	// 只需要遍历前缀所在的目录
+	dir := path.Dir(prefix)
+	if dir == "." {
+		dir = ""
+	}
+	err = s.walk(ctx, client, dir, func(rel string, fileInfo os.FileInfo) (bool, error) {
+		if fileInfo.IsDir() {
+			// 跳过在之前的分页中已经遍历完的目录
+			return token == "" || comparePath(rel, token) >= 0 || strings.HasPrefix(token, rel+"/"), nil
+		}
+		if !strings.HasPrefix(rel, prefix) || (token != "" && comparePath(rel, token) <= 0) {
+			return false, nil
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			return false, errStopWalk
+		}
+		result.Files = append(result.Files, &File{
+			ModTime: fileInfo.ModTime(),
+			Name:    rel,
+			Size:    fileInfo.Size(),
+			IsDir:   false,
+			Header:  map[string]string{},
+		})
+		return false, nil
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return
+}
+
+func (s *SftpAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	dir := strings.TrimSuffix(dirPrefix(prefix), "/")
+	dirPath, err := s.fullPath(dir)
+	if err != nil {
+		return
+	}
+	client, err := s.conn()
+	if err != nil {
+		return
+	}
+	entries, err := client.ReadDir(dirPath)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return
+	}
+	for _, entry := range entries {
+		files = append(files, &File{
+			ModTime: entry.ModTime(),
+			Name:    path.Join(dir, entry.Name()),
+			Size:    entry.Size(),
+			IsDir:   entry.IsDir(),
+			Header:  map[string]string{},
+		})
+	}
+	return
+}
+
+// walk 按文件名顺序深度优先遍历目录，与 filepath.WalkDir 的顺序一致，fn 返回 true 时进入子目录
+func (s *SftpAdapter) walk(ctx context.Context, client *sftp.Client, dir string, fn func(rel string, fileInfo os.FileInfo) (bool, error)) (err error) {
+	dirPath, err := s.fullPath(dir)
+	if err != nil {
+		return
+	}
+	entries, err := client.ReadDir(dirPath)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		rel := path.Join(dir, entry.Name())
+		enter, err := fn(rel, entry)
+		if err != nil {
+			return err
+		}
+		if enter && entry.IsDir() {
+			if err = s.walk(ctx, client, rel, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
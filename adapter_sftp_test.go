@@ -0,0 +1,321 @@
+package filesys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpTestServer 进程内的SFTP服务器，文件保存在 sftp.InMemHandler 的内存文件系统中
+type sftpTestServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	mem      sftp.Handlers
+
+	mu   sync.Mutex
+	busy map[string]bool // 被占用的文件，不能重命名和删除
+	full bool            // 写入的文件在关闭时报告磁盘已满
+}
+
+func newSftpTestServer(t *testing.T) *sftpTestServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(password) == "pass" {
+				return nil, nil
+			}
+			return nil, errors.New("permission denied")
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sftpTestServer{listener: listener, config: config, mem: sftp.InMemHandler(), busy: map[string]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	return s
+}
+
+// adapter 创建连接到该服务器的适配器
+func (s *sftpTestServer) adapter(t *testing.T) *SftpAdapter {
+	t.Helper()
+	addr := s.listener.Addr().(*net.TCPAddr)
+	adapter, err := NewAdapterSftp(ConfigSftp{
+		Host:                  addr.IP.String(),
+		Port:                  addr.Port,
+		Username:              "user",
+		Password:              "pass",
+		InsecureIgnoreHostKey: true,
+		Path:                  "/data",
+		Domain:                "http://localhost",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		adapter.(*SftpAdapter).Close()
+	})
+	return adapter.(*SftpAdapter)
+}
+
+func (s *sftpTestServer) serve(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+			}
+		}()
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  s.mem.FileGet,
+			FilePut:  s,
+			FileCmd:  s,
+			FileList: s.mem.FileList,
+		})
+		go func() {
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
+
+func (s *sftpTestServer) isBusy(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.busy[name]
+}
+
+func (s *sftpTestServer) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	writer, err := s.mem.FilePut.Filewrite(r)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.full {
+		return &sftpFullWriter{writer}, nil
+	}
+	return writer, nil
+}
+
+func (s *sftpTestServer) Filecmd(r *sftp.Request) error {
+	if (r.Method == "Rename" || r.Method == "Remove") && s.isBusy(r.Filepath) {
+		return os.ErrPermission
+	}
+	return s.mem.FileCmd.Filecmd(r)
+}
+
+func (s *sftpTestServer) PosixRename(r *sftp.Request) error {
+	if s.isBusy(r.Filepath) {
+		return os.ErrPermission
+	}
+	return s.mem.FileCmd.(sftp.PosixRenameFileCmder).PosixRename(r)
+}
+
+// sftpFullWriter 写入成功但关闭时失败，模拟超出配额
+type sftpFullWriter struct {
+	io.WriterAt
+}
+
+func (w *sftpFullWriter) Close() error {
+	return errors.New("disk full")
+}
+
+// setSftpExtensions 设置服务器支持的扩展，测试结束后恢复
+func setSftpExtensions(t *testing.T, extensions ...string) {
+	if err := sftp.SetSFTPExtensions(extensions...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	})
+}
+
+func TestSftpAdapter(t *testing.T) {
+	server := newSftpTestServer(t)
+	adapter := server.adapter(t)
+	ctx := context.Background()
+	for _, name := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir.txt"} {
+		if err := adapter.Upload(ctx, name, strings.NewReader("content of "+name), -1); err != nil {
+			t.Fatalf("Upload(%s): %v", name, err)
+		}
+	}
+
+	info, err := adapter.GetInfo(ctx, "/dir/b.txt")
+	if err != nil || info.Size != int64(len("content of dir/b.txt")) || info.Name != "dir/b.txt" {
+		t.Errorf("GetInfo = %+v, %v", info, err)
+	}
+	if _, err = adapter.GetInfo(ctx, "missing.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("GetInfo missing file: got %v, want ErrNotExist", err)
+	}
+	if _, err = adapter.GetInfo(ctx, "../a.txt"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("GetInfo outside the root: got %v, want ErrInvalidPath", err)
+	}
+	body, err := adapter.DownloadRange(ctx, "a.txt", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "tent" {
+		t.Errorf("DownloadRange = %q, want %q", data, "tent")
+	}
+
+	// 按目录层级顺序分页
+	want := []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir.txt"}
+	for _, limit := range []int{1, 2, 100} {
+		var (
+			got   []string
+			token string
+		)
+		for page := 0; page <= len(want); page++ {
+			result, err := adapter.ListPage(ctx, "", token, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, fileNames(result.Files)...)
+			if token = result.NextToken; token == "" {
+				break
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ListPage limit %d = %v, want %v", limit, got, want)
+		}
+	}
+	files, err := adapter.ListDir(ctx, "dir")
+	if got, want := fileNames(files), []string{"dir/b.txt", "dir/sub/"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir(dir) = %v, %v, want %v", got, err, want)
+	}
+
+	// 删除失败的文件不影响其他文件，错误合并返回，客户端删除文件失败时会再尝试删除目录，返回的是删除目录的错误
+	server.mu.Lock()
+	server.busy["/data/dir/b.txt"] = true
+	server.mu.Unlock()
+	err = adapter.Delete(ctx, "dir/b.txt", "missing.txt", "../a.txt", "a.txt")
+	if err == nil || !strings.Contains(err.Error(), "dir/b.txt") || !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Delete = %v, want the dir/b.txt error and ErrInvalidPath", err)
+	}
+	if err = adapter.IsExist(ctx, "a.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("IsExist(a.txt) after Delete: got %v, want ErrNotExist", err)
+	}
+	if err = adapter.IsExist(ctx, "dir/b.txt"); err != nil {
+		t.Errorf("busy file deleted: %v", err)
+	}
+}
+
+func TestSftpAdapterUploadCloseError(t *testing.T) {
+	server := newSftpTestServer(t)
+	adapter := server.adapter(t)
+	server.mu.Lock()
+	server.full = true
+	server.mu.Unlock()
+	// 服务器在关闭文件时才报告写入失败
+	if err := adapter.Upload(context.Background(), "a.txt", strings.NewReader("hello"), 5); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("Upload = %v, want the close error", err)
+	}
+}
+
+func TestSftpAdapterMove(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		t.Run(fmt.Sprintf("posixRename=%v", posixRename), func(t *testing.T) {
+			if !posixRename {
+				// 不支持 posix-rename 时，标准的 rename 不允许覆盖已存在的文件
+				setSftpExtensions(t, "statvfs@openssh.com")
+			}
+			server := newSftpTestServer(t)
+			adapter := server.adapter(t)
+			ctx := context.Background()
+			for _, name := range []string{"src.txt", "dst.txt", "busy.txt"} {
+				if err := adapter.Upload(ctx, name, strings.NewReader(name), -1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := adapter.Move(ctx, "src.txt", "dst.txt"); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+			if got := readObject(t, adapter, "dst.txt"); got != "src.txt" {
+				t.Fatalf("dst.txt = %q after Move", got)
+			}
+			if err := adapter.IsExist(ctx, "src.txt"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("IsExist(src.txt) after Move: got %v, want ErrNotExist", err)
+			}
+			if err := adapter.Move(ctx, "dst.txt", "new/dst.txt"); err != nil {
+				t.Fatalf("Move to a new directory: %v", err)
+			}
+			if err := adapter.Move(ctx, "new/dst.txt", "dst.txt"); err != nil {
+				t.Fatal(err)
+			}
+
+			// 移动到自身时不删除文件
+			if err := adapter.Move(ctx, "dst.txt", "/dst.txt"); err != nil {
+				t.Fatalf("Move onto itself: %v", err)
+			}
+			if got := readObject(t, adapter, "dst.txt"); got != "src.txt" {
+				t.Fatalf("dst.txt = %q after Move onto itself", got)
+			}
+			if err := adapter.Move(ctx, "missing.txt", "dst.txt"); !errors.Is(err, ErrNotExist) {
+				t.Fatalf("Move missing file: got %v, want ErrNotExist", err)
+			}
+
+			// 重命名失败时保留目标文件，不留下备份文件
+			server.mu.Lock()
+			server.busy["/data/busy.txt"] = true
+			server.mu.Unlock()
+			if err := adapter.Move(ctx, "busy.txt", "dst.txt"); err == nil {
+				t.Fatal("Move busy file: want error")
+			}
+			if got := readObject(t, adapter, "dst.txt"); got != "src.txt" {
+				t.Fatalf("dst.txt = %q after a failed Move", got)
+			}
+			files, err := adapter.Lists(ctx, "")
+			if got, want := fileNames(files), []string{"busy.txt", "dst.txt"}; err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("Lists after a failed Move = %v, %v, want %v", got, err, want)
+			}
+		})
+	}
+}
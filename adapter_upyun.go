@@ -2,13 +2,18 @@ package filesys
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gogf/gf/v2/util/gvalid"
 	"io"
-	"os"
-	"path/filepath"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +29,7 @@ type ConfigUpYun struct {
 	Expire   int64  `json:"expire"`
 }
 
+// UpYunAdapter 又拍云适配器，不支持分片上传，见 MultipartConfig
 type UpYunAdapter struct {
 	config *ConfigUpYun
 	client *upyun.UpYun
@@ -55,9 +61,14 @@ func NewAdapterUpYun(i interface{}) (Adapter, error) {
 	}, nil
 }
 
+// Bucket 存储桶名称
+func (u *UpYunAdapter) Bucket() string {
+	return u.config.Bucket
+}
+
 func (u *UpYunAdapter) IsExist(ctx context.Context, object string) (err error) {
 	_, err = u.client.GetInfo(objectAbs(object))
-	return
+	return upyunError(err)
 }
 
 func (u *UpYunAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
@@ -70,28 +81,27 @@ func (u *UpYunAdapter) Upload(ctx context.Context, path string, reader io.Reader
 			h[k] = v
 		}
 	}
+	if size >= 0 {
+		// 中间件包装后SDK无法从 reader 的类型获取长度
+		h["Content-Length"] = strconv.FormatInt(size, 10)
+	}
 	err = u.client.Put(&upyun.PutObjectConfig{
 		Path:    objectAbs(path),
 		Reader:  reader,
 		Headers: h,
 	})
-	return
+	return upyunError(err)
 }
 
 func (u *UpYunAdapter) Delete(ctx context.Context, objects ...string) (err error) {
-	var errs []string
+	var errs []error
 	for _, object := range objects {
 		err = u.client.Delete(&upyun.DeleteObjectConfig{
 			Path: objectAbs(object),
 		})
-		if err != nil {
-			errs = append(errs, err.Error())
-		}
-	}
-	if len(errs) > 0 {
-		err = errors.New(strings.Join(errs, "; "))
+		errs = append(errs, upyunError(err))
 	}
-	return
+	return joinErrors(errs...)
 }
 
 // GetSignURL https://help.upyun.com/knowledge-base/cdn-token-limite/
@@ -110,35 +120,195 @@ func (u *UpYunAdapter) GetSignURL(ctx context.Context, object string, expire ...
 	return u.config.Domain + path + "?_upt=" + sign, nil
 }
 
+// GetUploadSignURL 又拍云只支持表单上传，生成指定文件名的上传策略和签名
+func (u *UpYunAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	policy, err := u.PostPolicy(ctx, &PostPolicyOptions{
+		Key:         object,
+		Expire:      expire,
+		ContentType: opts.ContentType,
+		MaxSize:     opts.MaxSize,
+	})
+	if err != nil {
+		return
+	}
+	return postUploadSign(policy), nil
+}
+
+// PostPolicy 生成表单上传策略，只限制前缀时按上传的文件名保存
+// https://help.upyun.com/knowledge-base/form_api/
+func (u *UpYunAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	exp := uploadExpire(opts.Expire, u.config.Expire)
+	deadline := time.Now().Add(time.Duration(exp) * time.Second)
+	options := map[string]interface{}{
+		"bucket":     u.config.Bucket,
+		"expiration": deadline.Unix(),
+	}
+	if opts.Key != "" {
+		options["save-key"] = objectAbs(opts.Key)
+	} else {
+		options["save-key"] = objectAbs(opts.KeyPrefix) + "{filename}{.suffix}"
+	}
+	if opts.ContentType != "" {
+		options["content-type"] = opts.ContentType
+	}
+	if opts.MaxSize > 0 {
+		options["content-length-range"] = fmt.Sprintf("%d,%d", opts.MinSize, opts.MaxSize)
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	return &PostPolicyResult{
+		URL: "https://v0.api.upyun.com/" + u.config.Bucket,
+		Fields: map[string]string{
+			"policy": encoded,
+			"authorization": u.client.MakeUnifiedAuth(&upyun.UnifiedAuthConfig{
+				Method: http.MethodPost,
+				Uri:    "/" + u.config.Bucket,
+				Policy: encoded,
+			}),
+		},
+		Expire: deadline,
+	}, nil
+}
+
 func (u *UpYunAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
-	chans := make(chan *upyun.FileInfo, 1000)
+	return listAll(ctx, u, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (u *UpYunAdapter) pathOrder() {}
+
+// ListPage 又拍云SDK没有开放分页标记，这里按文件名顺序逐个目录遍历，跳过在之前的分页中已经遍历完的目录和 token 及之前的文件，
+// token 对应的文件在分页之间被删除时也能从下一个文件继续
+func (u *UpYunAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
 	prefix = objectRel(prefix)
-	u.client.List(&upyun.GetObjectsConfig{
-		Path:        prefix,
-		ObjectsChan: chans,
+	result = &ListResult{}
+	// 只需要遍历前缀所在的目录
+	dir := path.Dir(prefix)
+	if dir == "." {
+		dir = ""
+	}
+	err = u.walk(ctx, dir, func(rel string, obj *upyun.FileInfo) (bool, error) {
+		if obj.IsDir {
+			if !strings.HasPrefix(rel+"/", prefix) && !strings.HasPrefix(prefix, rel+"/") {
+				return false, nil
+			}
+			return token == "" || comparePath(rel, token) >= 0 || strings.HasPrefix(token, rel+"/"), nil
+		}
+		if !strings.HasPrefix(rel, prefix) || (token != "" && comparePath(rel, token) <= 0) {
+			return false, nil
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			return false, errStopWalk
+		}
+		result.Files = append(result.Files, upyunFile(rel, obj))
+		return false, nil
 	})
-	for obj := range chans {
-		files = append(files, &File{
-			ModTime: obj.Time,
-			Size:    obj.Size,
-			IsDir:   obj.IsDir,
-			Header:  obj.Meta, // 注意：这里获取不到文件的header
-			Name:    filepath.Join(prefix, objectRel(obj.Name)),
-		})
+	if err == errStopWalk {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
 	}
 	return
 }
 
-func (u *UpYunAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
-	file := new(os.File)
-	_, err = u.client.Get(&upyun.GetObjectConfig{
-		Path:   objectAbs(object),
-		Writer: file,
+func (u *UpYunAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	dir := strings.TrimSuffix(dirPrefix(prefix), "/")
+	entries, err := u.readDir(ctx, dir)
+	if err != nil {
+		return
+	}
+	for _, obj := range entries {
+		files = append(files, upyunFile(path.Join(dir, objectRel(obj.Name)), obj))
+	}
+	return
+}
+
+// readDir 列出目录下的文件和子目录，按文件名排序，目录不存在时返回空
+func (u *UpYunAdapter) readDir(ctx context.Context, dir string) (entries []*upyun.FileInfo, err error) {
+	chans := make(chan *upyun.FileInfo, 100)
+	quit := make(chan bool)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- u.client.List(&upyun.GetObjectsConfig{
+			Path:        objectAbs(dir),
+			ObjectsChan: chans,
+			QuitChan:    quit,
+		})
+	}()
+	for obj := range chans {
+		if err = ctx.Err(); err != nil {
+			close(quit)
+			for range chans {
+			}
+			break
+		}
+		entries = append(entries, obj)
+	}
+	if errList := upyunError(<-errs); errList != nil && err == nil {
+		if errors.Is(errList, ErrNotExist) {
+			return nil, nil
+		}
+		err = errList
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
 	})
-	body = file
+	return
+}
+
+// walk 按文件名顺序深度优先遍历目录，fn 返回 true 时进入子目录
+func (u *UpYunAdapter) walk(ctx context.Context, dir string, fn func(rel string, obj *upyun.FileInfo) (bool, error)) (err error) {
+	entries, err := u.readDir(ctx, dir)
 	if err != nil {
 		return
 	}
+	for _, obj := range entries {
+		rel := path.Join(dir, objectRel(obj.Name))
+		enter, err := fn(rel, obj)
+		if err != nil {
+			return err
+		}
+		if enter && obj.IsDir {
+			if err = u.walk(ctx, rel, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Download 又拍云SDK只支持写入 io.Writer，这里通过管道转换为 io.ReadCloser
+func (u *UpYunAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	// 先获取一次文件信息，以便及时返回文件不存在等错误
+	if err = u.IsExist(ctx, object); err != nil {
+		return
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_, errGet := u.client.Get(&upyun.GetObjectConfig{
+			Path:   objectAbs(object),
+			Writer: writer,
+		})
+		writer.CloseWithError(upyunError(errGet))
+	}()
+	body = reader
 	return
 }
 
@@ -146,7 +316,7 @@ func (u *UpYunAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	var fileInfo *upyun.FileInfo
 	fileInfo, err = u.client.GetInfo(objectAbs(object))
 	if err != nil {
-		return
+		return nil, upyunError(err)
 	}
 	info = &File{
 		ModTime: fileInfo.Time,
@@ -157,3 +327,52 @@ func (u *UpYunAdapter) GetInfo(ctx context.Context, object string) (info *File,
 	}
 	return
 }
+
+// Copy https://help.upyun.com/knowledge-base/rest_api/#e5a48de588b6e69687e4bbb6
+func (u *UpYunAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	return u.putSource(dst, "X-Upyun-Copy-Source", src)
+}
+
+// Move https://help.upyun.com/knowledge-base/rest_api/#e7a7bbe58aa8e69687e4bbb6
+func (u *UpYunAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	return u.putSource(dst, "X-Upyun-Move-Source", src)
+}
+
+// putSource 又拍云SDK没有开放复制和移动，通过带源文件header的空 PUT 请求实现
+func (u *UpYunAdapter) putSource(dst, header, src string) (err error) {
+	err = u.client.Put(&upyun.PutObjectConfig{
+		Path:   objectAbs(dst),
+		Reader: strings.NewReader(""),
+		Headers: map[string]string{
+			header:           "/" + u.config.Bucket + objectAbs(src),
+			"Content-Length": "0",
+		},
+	})
+	return upyunError(err)
+}
+
+// upyunFile 把又拍云的文件信息转换为 File
+func upyunFile(name string, obj *upyun.FileInfo) *File {
+	return &File{
+		ModTime: obj.Time,
+		Size:    obj.Size,
+		IsDir:   obj.IsDir,
+		Header:  obj.Meta, // 注意：列表中获取不到文件的header
+		Name:    name,
+	}
+}
+
+// upyunStatusRe 又拍云SDK只以文本形式返回错误，例如：HEAD 404 {...}
+var upyunStatusRe = regexp.MustCompile(`\b(?:GET|HEAD|PUT|POST|DELETE) (\d{3})\b`)
+
+// upyunError 对又拍云返回的错误进行归类
+func upyunError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if m := upyunStatusRe.FindStringSubmatch(err.Error()); len(m) == 2 {
+		status, _ := strconv.Atoi(m[1])
+		return statusError(status, err)
+	}
+	return err
+}
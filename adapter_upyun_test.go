@@ -0,0 +1,103 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/upyun/go-sdk/upyun"
+)
+
+// upyunTestServer 模拟又拍云的目录列表接口
+type upyunTestServer struct {
+	mu    sync.Mutex
+	files map[string]int64
+}
+
+func (s *upyunTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dir := path.Clean(strings.TrimPrefix(r.URL.Path, "/bucket"))
+	if r.Method != http.MethodGet || r.Header.Get("X-UpYun-Folder") != "true" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	children := map[string]string{}
+	for name, size := range s.files {
+		rel := strings.TrimPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+		if rel == name && dir != "/" {
+			continue
+		}
+		if i := strings.IndexByte(rel, '/'); i >= 0 {
+			children[rel[:i]] = fmt.Sprintf("%s\tF\t0\t0", rel[:i])
+		} else {
+			children[rel] = fmt.Sprintf("%s\tN\t%d\t0", rel, size)
+		}
+	}
+	if len(children) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Upyun-List-Iter", "g2gCZAAEbmV4dGQAA2VvZg")
+	for _, line := range children {
+		fmt.Fprintln(w, line)
+	}
+}
+
+func TestUpYunAdapterListPage(t *testing.T) {
+	server := &upyunTestServer{files: map[string]int64{
+		"/a/1": 1, "/a/2": 2, "/b/1": 3, "/b/2": 4, "/b/c/1": 5, "/c": 6,
+	}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	host, _ := url.Parse(httpServer.URL)
+	adapter := &UpYunAdapter{
+		config: &ConfigUpYun{Bucket: "bucket"},
+		client: upyun.NewUpYun(&upyun.UpYunConfig{
+			Bucket:   "bucket",
+			Operator: "operator",
+			Password: "password",
+			Hosts:    map[string]string{"v0.api.upyun.com": host.Host},
+		}),
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		prefix, token string
+		deleted       string // 列出前删除的文件，模拟 token 对应的文件在分页之间被删除
+		files         []string
+		nextToken     string
+	}{
+		{files: []string{"a/1", "a/2", "b/1"}, nextToken: "b/1"},
+		{token: "b/1", files: []string{"b/2", "b/c/1", "c"}},
+		{token: "a/2", deleted: "/a/2", files: []string{"b/1", "b/2", "b/c/1"}, nextToken: "b/c/1"},
+		{prefix: "b/", files: []string{"b/1", "b/2", "b/c/1"}},
+		{prefix: "b/c", token: "b/c/1"},
+		{prefix: "missing/"},
+	}
+	for _, tt := range tests {
+		if tt.deleted != "" {
+			server.mu.Lock()
+			delete(server.files, tt.deleted)
+			server.mu.Unlock()
+		}
+		result, err := adapter.ListPage(ctx, tt.prefix, tt.token, 3)
+		if err != nil {
+			t.Fatalf("ListPage(%q, %q): %v", tt.prefix, tt.token, err)
+		}
+		var names []string
+		for _, file := range result.Files {
+			names = append(names, file.Name)
+		}
+		if !reflect.DeepEqual(names, tt.files) || result.NextToken != tt.nextToken {
+			t.Errorf("ListPage(%q, %q) = %v next %q, want %v next %q", tt.prefix, tt.token, names, result.NextToken, tt.files, tt.nextToken)
+		}
+	}
+}
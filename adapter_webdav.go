@@ -0,0 +1,713 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/util/guid"
+	"github.com/gogf/gf/v2/util/gvalid"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WebdavAuthBasic  = "basic"  // Basic 认证
+	WebdavAuthDigest = "digest" // Digest 认证
+)
+
+type ConfigWebdav struct {
+	Endpoint           string `json:"endpoint" v:"required#Endpoint不能为空"` // WebDAV 地址，例如 https://cloud.example.com/remote.php/dav/files/user
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	Auth               string `json:"auth"`               // 认证方式，basic 或 digest，为空时使用 basic
+	Path               string `json:"path"`               // 存储目录，相对于 Endpoint
+	Domain             string `json:"domain"`             // 访问域名，为空时返回 WebDAV 地址
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"` // 不校验服务器证书，仅用于测试环境
+	Timeout            int64  `json:"timeout"`            // 请求超时时间，单位秒，为0时不限制
+}
+
+type WebdavAdapter struct {
+	config   *ConfigWebdav
+	endpoint *url.URL
+	root     string // Endpoint 的路径，存储目录在该路径下
+	client   *http.Client
+	digest   *webdavDigest
+	dirs     sync.Map // 已经创建过的目录
+}
+
+func NewAdapterWebdav(i interface{}) (Adapter, error) {
+	cfg := (*ConfigWebdav)(nil)
+	if err := gconv.Scan(i, &cfg); err != nil {
+		return nil, err
+	}
+	if verr := gvalid.New().Data(&cfg).Run(context.Background()); verr != nil {
+		if err := verr.FirstError(); err != nil {
+			return nil, err
+		}
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, gerror.Wrapf(err, "Endpoint[%s]不合法", cfg.Endpoint)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, gerror.Newf("Endpoint[%s]不合法", cfg.Endpoint)
+	}
+	root := path.Join("/", endpoint.Path)
+	endpoint.Path = path.Join(root, cfg.Path)
+	endpoint.RawPath = ""
+
+	w := &WebdavAdapter{
+		config:   cfg,
+		endpoint: endpoint,
+		root:     root,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify},
+			},
+		},
+	}
+	switch cfg.Auth {
+	case "", WebdavAuthBasic:
+	case WebdavAuthDigest:
+		w.digest = &webdavDigest{}
+	default:
+		return nil, gerror.Newf("认证方式[%s]不支持", cfg.Auth)
+	}
+	return w, nil
+}
+
+// fullPath 获取文件在服务器上的完整路径，不允许跳出存储目录
+func (w *WebdavAdapter) fullPath(object string) (string, error) {
+	filePath := path.Join(w.endpoint.Path, object)
+	if filePath != w.endpoint.Path && !strings.HasPrefix(filePath, strings.TrimSuffix(w.endpoint.Path, "/")+"/") {
+		return "", invalidPathError(object)
+	}
+	return filePath, nil
+}
+
+// objectURL 获取文件的访问地址，目录以 "/" 结尾
+func (w *WebdavAdapter) objectURL(object string, isDir bool) (string, error) {
+	filePath, err := w.fullPath(object)
+	if err != nil {
+		return "", err
+	}
+	return w.pathURL(filePath, isDir), nil
+}
+
+// pathURL 根据服务器上的路径生成访问地址
+func (w *WebdavAdapter) pathURL(filePath string, isDir bool) string {
+	if isDir && !strings.HasSuffix(filePath, "/") {
+		filePath += "/"
+	}
+	u := *w.endpoint
+	u.Path = filePath
+	return u.String()
+}
+
+// request 发送请求，状态码不是2xx时返回错误
+func (w *WebdavAdapter) request(ctx context.Context, method, link string, body io.Reader, size int64, header map[string]string) (resp *http.Response, err error) {
+	if w.digest != nil && body != nil && !w.digest.ready() {
+		// 请求体只能读取一次，Digest 认证需要先获取服务器的 challenge
+		if err = w.challenge(ctx); err != nil {
+			return
+		}
+	}
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequestWithContext(ctx, method, link, body)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil && size >= 0 {
+			req.ContentLength = size
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w.authorize(req)
+		if resp, err = w.client.Do(req); err != nil {
+			return nil, err
+		}
+		// challenge 过期或者尚未获取时，更新后重试一次
+		authenticate := resp.Header.Get("WWW-Authenticate")
+		if resp.StatusCode == http.StatusUnauthorized && w.digest != nil && retry == 0 && w.digest.update(authenticate) {
+			if body == nil || req.GetBody != nil {
+				resp.Body.Close()
+				if req.GetBody != nil {
+					if body, err = req.GetBody(); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			if webdavDigestStale(authenticate) {
+				// 请求体已经被读取无法重发，challenge 已经更新，返回临时错误由调用方或重试中间件重新发送
+				resp.Body.Close()
+				return nil, temporaryError(gerror.Newf("%s %s 请求失败：%s，Digest nonce 已过期", method, link, resp.Status))
+			}
+		}
+		break
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, statusError(resp.StatusCode, &webdavStatusError{
+			status: resp.StatusCode,
+			err:    gerror.Newf("%s %s 请求失败：%s %s", method, link, resp.Status, bytes.TrimSpace(message)),
+		})
+	}
+	return
+}
+
+// call 发送请求并丢弃响应内容
+func (w *WebdavAdapter) call(ctx context.Context, method, link string, body io.Reader, size int64, header map[string]string) (err error) {
+	resp, err := w.request(ctx, method, link, body, size, header)
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// challenge 获取 Digest 认证的 challenge
+func (w *WebdavAdapter) challenge(ctx context.Context) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, w.endpoint.String()+"/", nil)
+	if err != nil {
+		return
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if !w.digest.update(resp.Header.Get("WWW-Authenticate")) {
+		return gerror.New("服务器没有返回 Digest 认证信息")
+	}
+	return
+}
+
+// authorize 设置请求的认证信息
+func (w *WebdavAdapter) authorize(req *http.Request) {
+	if w.config.Username == "" {
+		return
+	}
+	if w.digest == nil {
+		req.SetBasicAuth(w.config.Username, w.config.Password)
+		return
+	}
+	if auth := w.digest.authorization(req.Method, req.URL.RequestURI(), w.config.Username, w.config.Password); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// mkdirAll 从 Endpoint 开始逐级创建目录，包括存储目录本身，目录已存在时服务器返回 405
+func (w *WebdavAdapter) mkdirAll(ctx context.Context, dir string) (err error) {
+	dirPath, err := w.fullPath(dir)
+	if err != nil {
+		return
+	}
+	if _, ok := w.dirs.Load(dirPath); ok {
+		return
+	}
+	rel := strings.Trim(strings.TrimPrefix(dirPath, w.root), "/")
+	if rel == "" {
+		return
+	}
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		sub := path.Join(w.root, strings.Join(parts[:i+1], "/"))
+		if _, ok := w.dirs.Load(sub); ok {
+			continue
+		}
+		err = w.call(ctx, "MKCOL", w.pathURL(sub, true), nil, 0, nil)
+		if err != nil && !webdavStatusIs(err, http.StatusMethodNotAllowed) {
+			return
+		}
+		w.dirs.Store(sub, struct{}{})
+	}
+	return nil
+}
+
+func (w *WebdavAdapter) IsExist(ctx context.Context, object string) (err error) {
+	_, err = w.GetInfo(ctx, object)
+	return
+}
+
+func (w *WebdavAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	link, err := w.objectURL(path, false)
+	if err != nil {
+		return
+	}
+	if err = w.mkdirAll(ctx, gfile.Dir(objectRel(path))); err != nil {
+		return
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	if err = w.call(ctx, http.MethodPut, link, reader, size, mergeHeaders(headers...)); webdavStatusIs(err, http.StatusConflict) {
+		// 上级目录被删除，下次上传时重新创建
+		w.dirs.Range(func(key, value interface{}) bool {
+			w.dirs.Delete(key)
+			return true
+		})
+	}
+	return
+}
+
+func (w *WebdavAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	var errs []error
+	for _, object := range objects {
+		link, errURL := w.objectURL(object, false)
+		if errURL != nil {
+			errs = append(errs, errURL)
+			continue
+		}
+		if err = w.call(ctx, http.MethodDelete, link, nil, 0, nil); err != nil && !errors.Is(err, ErrNotExist) {
+			errs = append(errs, gerror.Wrapf(err, "删除文件[%s]失败", object))
+		}
+	}
+	return joinErrors(errs...)
+}
+
+// GetSignURL 配置了 Domain 时返回 Domain 下的地址，否则返回 WebDAV 地址，访问时需要认证
+func (w *WebdavAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	if w.config.Domain != "" {
+		link = gfile.Join(w.config.Domain, object)
+		return
+	}
+	return w.objectURL(object, false)
+}
+
+func (w *WebdavAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return w.DownloadRange(ctx, object, 0, 0)
+}
+
+func (w *WebdavAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	link, err := w.objectURL(object, false)
+	if err != nil {
+		return
+	}
+	header := map[string]string{}
+	if offset > 0 || length > 0 {
+		header["Range"] = httpRange(offset, length)
+	}
+	resp, err := w.request(ctx, http.MethodGet, link, nil, 0, header)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, notExistError(object)
+		}
+		if webdavStatusIs(err, http.StatusRequestedRangeNotSatisfiable) {
+			// 偏移量超出文件大小时返回空内容
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return
+	}
+	body = resp.Body
+	if resp.StatusCode != http.StatusPartialContent && offset > 0 {
+		// 服务器不支持 Range 时跳过 offset 之前的内容
+		if _, err = io.CopyN(io.Discard, body, offset); err != nil && err != io.EOF {
+			body.Close()
+			return nil, err
+		}
+	}
+	return limitBody(body, length), nil
+}
+
+func (w *WebdavAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	files, err := w.propfind(ctx, objectRel(object), "0")
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, notExistError(object)
+		}
+		return
+	}
+	if len(files) == 0 {
+		return nil, notExistError(object)
+	}
+	info = files[0]
+	info.Name = objectRel(object)
+	return
+}
+
+func (w *WebdavAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	return w.transfer(ctx, "COPY", src, dst)
+}
+
+func (w *WebdavAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	return w.transfer(ctx, "MOVE", src, dst)
+}
+
+// transfer 复制或移动文件，目标文件已存在时覆盖
+func (w *WebdavAdapter) transfer(ctx context.Context, method, src, dst string) (err error) {
+	srcLink, err := w.objectURL(src, false)
+	if err != nil {
+		return
+	}
+	dstLink, err := w.objectURL(dst, false)
+	if err != nil {
+		return
+	}
+	if err = w.mkdirAll(ctx, path.Dir(objectRel(dst))); err != nil {
+		return
+	}
+	err = w.call(ctx, method, srcLink, nil, 0, map[string]string{
+		"Destination": dstLink,
+		"Overwrite":   "T",
+	})
+	if errors.Is(err, ErrNotExist) {
+		return notExistError(src)
+	}
+	return
+}
+
+func (w *WebdavAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return listAll(ctx, w, prefix)
+}
+
+// pathOrder 分页按目录层级顺序
+func (w *WebdavAdapter) pathOrder() {}
+
+// ListPage 按目录层级顺序遍历，token 为上一页最后一个文件的相对路径
+func (w *WebdavAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	prefix = objectRel(prefix)
+	result = &ListResult{}
+	// 只需要遍历前缀所在的目录
+	dir := path.Dir(prefix)
+	if dir == "." {
+		dir = ""
+	}
+	err = w.walk(ctx, dir, func(file *File) (bool, error) {
+		if file.IsDir {
+			// 跳过在之前的分页中已经遍历完的目录
+			return token == "" || comparePath(file.Name, token) >= 0 || strings.HasPrefix(token, file.Name+"/"), nil
+		}
+		if !strings.HasPrefix(file.Name, prefix) || (token != "" && comparePath(file.Name, token) <= 0) {
+			return false, nil
+		}
+		if len(result.Files) >= limit {
+			result.NextToken = result.Files[len(result.Files)-1].Name
+			return false, errStopWalk
+		}
+		result.Files = append(result.Files, file)
+		return false, nil
+	})
+	if err == errStopWalk {
+		err = nil
+	}
+	return
+}
+
+func (w *WebdavAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return w.readDir(ctx, strings.TrimSuffix(dirPrefix(prefix), "/"))
+}
+
+// readDir 列出目录下的文件和子目录，按文件名排序，目录不存在时返回空
+func (w *WebdavAdapter) readDir(ctx context.Context, dir string) (files []*File, err error) {
+	list, err := w.propfind(ctx, dir, "1")
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return
+	}
+	for _, file := range list {
+		// 结果中包含目录本身
+		if file.Name != dir && path.Dir(file.Name) == path.Clean("./"+dir) {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return
+}
+
+// walk 按文件名顺序深度优先遍历目录，与 filepath.WalkDir 的顺序一致，fn 返回 true 时进入子目录
+func (w *WebdavAdapter) walk(ctx context.Context, dir string, fn func(file *File) (bool, error)) (err error) {
+	files, err := w.readDir(ctx, dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		enter, err := fn(file)
+		if err != nil {
+			return err
+		}
+		if enter && file.IsDir {
+			if err = w.walk(ctx, file.Name, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// webdavPropfindBody 需要查询的属性
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/><d:getcontenttype/><d:getetag/></d:prop></d:propfind>`
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+				ContentType   string `xml:"DAV: getcontenttype"`
+				ETag          string `xml:"DAV: getetag"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// propfind 查询文件属性，depth 为 "0" 时只查询文件本身，为 "1" 时同时查询目录下的文件
+func (w *WebdavAdapter) propfind(ctx context.Context, object string, depth string) (files []*File, err error) {
+	link, err := w.objectURL(object, depth != "0")
+	if err != nil {
+		return
+	}
+	body := strings.NewReader(webdavPropfindBody)
+	resp, err := w.request(ctx, "PROPFIND", link, body, body.Size(), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var result webdavMultistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, gerror.Wrap(err, "解析PROPFIND响应失败")
+	}
+	for _, response := range result.Responses {
+		name, err := w.hrefName(response.Href)
+		if err != nil {
+			return nil, err
+		}
+		file := &File{Name: name, Header: map[string]string{}}
+		for _, propstat := range response.Propstats {
+			// 只使用状态为 200 的属性，服务器不支持的属性状态为 404
+			if fields := strings.Fields(propstat.Status); len(fields) > 1 && fields[1] != "200" {
+				continue
+			}
+			prop := propstat.Prop
+			if prop.ResourceType.Collection != nil {
+				file.IsDir = true
+			}
+			if prop.ContentLength != "" {
+				file.Size, _ = strconv.ParseInt(prop.ContentLength, 10, 64)
+			}
+			if prop.LastModified != "" {
+				file.ModTime, _ = http.ParseTime(prop.LastModified)
+				file.Header["Last-Modified"] = prop.LastModified
+			}
+			if prop.ContentType != "" {
+				file.Header["Content-Type"] = prop.ContentType
+			}
+			if prop.ETag != "" {
+				file.Header["ETag"] = prop.ETag
+			}
+		}
+		if file.IsDir {
+			file.Size = 0
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+// hrefName 把响应中的 href 转换为相对于存储目录的文件名
+func (w *WebdavAdapter) hrefName(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", gerror.Wrapf(err, "href[%s]不合法", href)
+	}
+	base := strings.TrimSuffix(w.endpoint.Path, "/")
+	name := path.Clean(u.Path)
+	if name != base && !strings.HasPrefix(name, base+"/") {
+		return "", gerror.Newf("href[%s]不在存储目录中", href)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, base), "/"), nil
+}
+
+// webdavStatusError 请求返回的状态码不是2xx
+type webdavStatusError struct {
+	status int
+	err    error
+}
+
+func (e *webdavStatusError) Error() string {
+	return e.err.Error()
+}
+
+// webdavStatusIs 判断请求错误的HTTP状态码
+func webdavStatusIs(err error, status int) bool {
+	var statusErr *webdavStatusError
+	return errors.As(err, &statusErr) && statusErr.status == status
+}
+
+// webdavDigest 保存服务器的 Digest challenge，生成认证信息，并发安全
+type webdavDigest struct {
+	mu        sync.Mutex
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	nc        int
+}
+
+// ready 是否已经获取到 challenge
+func (d *webdavDigest) ready() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nonce != ""
+}
+
+// update 解析 WWW-Authenticate 响应头，不是 Digest 认证时返回 false
+func (d *webdavDigest) update(header string) bool {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return false
+	}
+	params := webdavAuthParams(header[7:])
+	if params["nonce"] == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.realm = params["realm"]
+	d.nonce = params["nonce"]
+	d.opaque = params["opaque"]
+	d.algorithm = params["algorithm"]
+	d.qop = ""
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			d.qop = "auth"
+		}
+	}
+	d.nc = 0
+	return true
+}
+
+// webdavDigestStale 判断 Digest challenge 是否因为 nonce 过期而返回，此时使用新的 nonce 重新发送即可
+func webdavDigestStale(header string) bool {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return false
+	}
+	return strings.EqualFold(webdavAuthParams(header[7:])["stale"], "true")
+}
+
+// authorization 生成 Authorization 请求头，尚未获取 challenge 时返回空
+func (d *webdavDigest) authorization(method, uri, username, password string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.nonce == "" {
+		return ""
+	}
+	var h func() hash.Hash
+	algorithm := strings.ToUpper(d.algorithm)
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return ""
+	}
+	sum := func(s string) string {
+		hh := h()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	d.nc++
+	nc := fmt.Sprintf("%08x", d.nc)
+	cnonce := guid.S()
+	ha1 := sum(username + ":" + d.realm + ":" + password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = sum(ha1 + ":" + d.nonce + ":" + cnonce)
+	}
+	ha2 := sum(method + ":" + uri)
+	var response string
+	if d.qop == "" {
+		response = sum(ha1 + ":" + d.nonce + ":" + ha2)
+	} else {
+		response = sum(ha1 + ":" + d.nonce + ":" + nc + ":" + cnonce + ":" + d.qop + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`, username, d.realm, d.nonce, uri, response)
+	if d.algorithm != "" {
+		auth += ", algorithm=" + d.algorithm
+	}
+	if d.opaque != "" {
+		auth += fmt.Sprintf(`, opaque="%s"`, d.opaque)
+	}
+	if d.qop != "" {
+		auth += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, d.qop, nc, cnonce)
+	}
+	return auth
+}
+
+// webdavAuthParams 解析认证参数，格式为 key=value 或 key="value"，以逗号分隔
+func webdavAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, ", ") {
+		idx := strings.IndexByte(s, '=')
+		if idx < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:idx]))
+		s = strings.TrimSpace(s[idx+1:])
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(s) {
+				end = len(s)
+			}
+			value = strings.ReplaceAll(s[1:end], `\`, "")
+			if end < len(s) {
+				end++
+			}
+			s = s[end:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params
+}
@@ -0,0 +1,167 @@
+package filesys
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webdavDigestServer 使用 Digest 认证的 WebDAV 服务器，只实现上传和删除
+type webdavDigestServer struct {
+	mu     sync.Mutex
+	nonce  int
+	files  map[string]string
+	locked map[string]bool // 被锁定的文件，不能删除
+	server *httptest.Server
+}
+
+func newWebdavDigestServer(t *testing.T) *webdavDigestServer {
+	s := &webdavDigestServer{nonce: 1, files: map[string]string{}, locked: map[string]bool{}}
+	s.server = httptest.NewServer(s)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// expireNonce 让当前的 nonce 过期
+func (s *webdavDigestServer) expireNonce() {
+	s.mu.Lock()
+	s.nonce++
+	s.mu.Unlock()
+}
+
+func (s *webdavDigestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	challenge := func(stale bool) {
+		header := fmt.Sprintf(`Digest realm="test", nonce="%s"`, nonce)
+		if stale {
+			header += ", stale=true"
+		}
+		w.Header().Set("WWW-Authenticate", header)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		challenge(false)
+		return
+	}
+	params := webdavAuthParams(auth[7:])
+	sum := func(s string) string {
+		h := md5.Sum([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	ha1 := sum(params["username"] + ":test:password")
+	ha2 := sum(r.Method + ":" + params["uri"])
+	if params["response"] != sum(ha1+":"+params["nonce"]+":"+ha2) {
+		challenge(false)
+		return
+	}
+	if params["nonce"] != nonce {
+		challenge(true)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.files[r.URL.Path] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	case "MKCOL":
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		_, ok := s.files[r.URL.Path]
+		switch {
+		case s.locked[r.URL.Path]:
+			w.WriteHeader(http.StatusLocked)
+		case ok:
+			delete(s.files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebdavAdapterDigestStaleNonce(t *testing.T) {
+	server := newWebdavDigestServer(t)
+	newAdapter := func(password string) Adapter {
+		adapter, err := NewAdapterWebdav(ConfigWebdav{
+			Endpoint: server.server.URL,
+			Username: "user",
+			Password: password,
+			Auth:     WebdavAuthDigest,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return adapter
+	}
+	adapter := newAdapter("password")
+	ctx := context.Background()
+	// io.MultiReader 没有 GetBody，请求体无法重发
+	upload := func(adapter Adapter, name, content string) error {
+		return adapter.Upload(ctx, name, io.MultiReader(strings.NewReader(content)), int64(len(content)))
+	}
+
+	if err := upload(adapter, "a.txt", "one"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	server.expireNonce()
+	err := upload(adapter, "a.txt", "two")
+	if !IsRetryable(err) || errors.Is(err, ErrPermission) {
+		t.Fatalf("Upload with stale nonce: got %v, want a retryable error", err)
+	}
+	if err = upload(adapter, "a.txt", "two"); err != nil {
+		t.Fatalf("Upload after challenge refresh: %v", err)
+	}
+
+	// 重试中间件重新发送可以 Seek 的请求体
+	server.expireNonce()
+	retry := NewRetryMiddleware(RetryConfig{BaseDelay: time.Millisecond})(adapter)
+	if err = retry.Upload(ctx, "b.txt", strings.NewReader("three"), 5); err != nil {
+		t.Fatalf("Upload with retry middleware: %v", err)
+	}
+	if server.files["/a.txt"] != "two" || server.files["/b.txt"] != "three" {
+		t.Fatalf("files = %v", server.files)
+	}
+
+	// 密码错误时不是临时错误
+	if err = upload(newAdapter("wrong"), "c.txt", "four"); !errors.Is(err, ErrPermission) || IsRetryable(err) {
+		t.Fatalf("Upload with wrong password: got %v, want ErrPermission", err)
+	}
+}
+
+func TestWebdavAdapterDelete(t *testing.T) {
+	server := newWebdavDigestServer(t)
+	adapter, err := NewAdapterWebdav(ConfigWebdav{
+		Endpoint: server.server.URL + "/dav",
+		Username: "user",
+		Password: "password",
+		Auth:     WebdavAuthDigest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.files = map[string]string{"/dav/a.txt": "a", "/dav/locked.txt": "locked", "/dav/c.txt": "c", "/c.txt": "outside"}
+	server.locked["/dav/locked.txt"] = true
+
+	// 删除失败的文件不影响其他文件，错误合并返回，不存在的文件忽略
+	err = adapter.Delete(context.Background(), "a.txt", "locked.txt", "missing.txt", "../c.txt", "c.txt")
+	if err == nil || !strings.Contains(err.Error(), "locked.txt") || !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("Delete = %v, want the locked.txt error and ErrInvalidPath", err)
+	}
+	if len(server.files) != 2 || server.files["/dav/locked.txt"] != "locked" || server.files["/c.txt"] != "outside" {
+		t.Fatalf("files after Delete = %v, want locked.txt and the file outside the root", server.files)
+	}
+}
@@ -0,0 +1,281 @@
+package filesys
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// defaultArchiveConcurrency 打包下载时默认同时预下载的文件数
+const defaultArchiveConcurrency = 4
+
+// ArchiveOptions 打包下载的参数，Objects 和 Prefix 至少指定一个，同时指定时先打包 Objects
+type ArchiveOptions struct {
+	Objects     []string // 需要打包的文件
+	Prefix      string   // 打包前缀下的全部文件
+	Format      string   // 归档格式，ArchiveZip、ArchiveTar 或 ArchiveTarGz，为空时使用 zip
+	StripPrefix string   // 归档中的文件名去掉该目录
+	Flatten     bool     // 归档中的文件名去掉目录，只保留文件名
+	Concurrency int      // 同时预下载的文件数，默认4
+	ErrorFile   string   // 有文件下载失败时，在归档中写入该文件记录失败原因，为空时不写入
+}
+
+// ArchiveError 打包下载时下载失败而被跳过的文件
+type ArchiveError struct {
+	Object string
+	Err    error
+}
+
+func (e *ArchiveError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Object, e.Err)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// archiveItem 预下载的文件，ready 关闭后 body 或 err 可用
+type archiveItem struct {
+	file  *File
+	body  io.ReadCloser
+	err   error
+	ready chan struct{}
+}
+
+// archiveStream 边下载边写入的归档
+type archiveStream struct {
+	zw *zip.Writer
+	tw *tar.Writer
+	gw *gzip.Writer
+}
+
+// DownloadArchive 把多个文件边下载边打包写入 w，适合"全部下载"，tar 格式的文件先下载到临时目录。
+// 打开下载流失败的文件会被跳过，返回跳过的文件，opts.ErrorFile 不为空时同时写入归档；写入 w 失败时返回错误
+func (c *Store) DownloadArchive(ctx context.Context, opts *ArchiveOptions, w io.Writer) (failed []*ArchiveError, err error) {
+	return downloadArchive(ctx, c.localAdapter, opts, w)
+}
+
+// downloadArchive 把多个文件打包后写入 w。
+// 最多同时打开 Concurrency 个文件的下载流，按顺序写入归档，不会把整个文件读入内存；
+// 打开下载流失败的文件会被跳过并返回，写入过程中出错时归档已损坏，直接返回错误
+func downloadArchive(ctx context.Context, adapter Adapter, opts *ArchiveOptions, w io.Writer) (failed []*ArchiveError, err error) {
+	if opts == nil || (len(opts.Objects) == 0 && opts.Prefix == "") {
+		return nil, gerror.New("Objects和Prefix不能同时为空")
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultArchiveConcurrency
+	}
+	stream, err := newArchiveStream(opts.Format, w)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		queue   = make(chan *archiveItem, concurrency)
+		slots   = make(chan struct{}, concurrency)
+		listErr error
+	)
+	go func() {
+		defer close(queue)
+		listErr = archiveFiles(ctx, adapter, opts, func(file *File) error {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			item := &archiveItem{file: file, ready: make(chan struct{})}
+			go func() {
+				defer close(item.ready)
+				item.open(ctx, adapter, stream.tw != nil)
+			}()
+			queue <- item
+			return nil
+		})
+	}()
+
+	names := make(map[string]bool)
+	for item := range queue {
+		<-item.ready
+		if err == nil {
+			if item.err != nil {
+				failed = append(failed, &ArchiveError{Object: item.file.Name, Err: item.err})
+			} else if err = stream.write(archiveEntryName(item.file.Name, opts, names), item.file, item.body); err != nil {
+				err = gerror.Wrapf(err, "打包文件[%s]失败", item.file.Name)
+				cancel()
+			}
+		}
+		if item.body != nil {
+			item.body.Close()
+		}
+		<-slots
+	}
+	if err != nil {
+		return
+	}
+	if listErr != nil {
+		return failed, listErr
+	}
+
+	if len(failed) > 0 && opts.ErrorFile != "" {
+		var report strings.Builder
+		for _, f := range failed {
+			report.WriteString(f.Error() + "\n")
+		}
+		name := archiveEntryName(opts.ErrorFile, &ArchiveOptions{}, names)
+		file := &File{Name: name, Size: int64(report.Len()), ModTime: time.Now()}
+		if err = stream.write(name, file, strings.NewReader(report.String())); err != nil {
+			return
+		}
+	}
+	return failed, stream.close()
+}
+
+// archiveFiles 依次列出需要打包的文件
+func archiveFiles(ctx context.Context, adapter Adapter, opts *ArchiveOptions, fn func(file *File) error) (err error) {
+	for _, object := range opts.Objects {
+		// 大小未知，打开下载流时获取文件信息
+		if err = fn(&File{Name: object, Size: -1}); err != nil {
+			return
+		}
+	}
+	if opts.Prefix == "" {
+		return
+	}
+	return walkFiles(ctx, adapter, opts.Prefix, func(file *File) error {
+		if file.IsDir || isDirKey(file.Name) {
+			return nil
+		}
+		return fn(file)
+	})
+}
+
+// open 获取文件信息并打开下载流。
+// tar 需要在写入内容前确定文件大小，列表中的大小可能已经过期，spool 为 true 时先下载到临时文件，使用实际下载的大小
+func (item *archiveItem) open(ctx context.Context, adapter Adapter, spool bool) {
+	if item.file.Size < 0 {
+		info, err := adapter.GetInfo(ctx, item.file.Name)
+		if err != nil {
+			item.err = err
+			return
+		}
+		if info.IsDir {
+			item.err = notExistError(item.file.Name)
+			return
+		}
+		item.file.Size, item.file.ModTime = info.Size, info.ModTime
+	}
+	item.body, item.err = adapter.Download(ctx, item.file.Name)
+	if item.err != nil || !spool {
+		return
+	}
+	body := item.body
+	defer body.Close()
+	item.body, item.file.Size, item.err = spoolArchiveBody(body)
+}
+
+// spoolArchiveBody 把下载流写入临时文件，返回的 body 关闭时删除临时文件
+func spoolArchiveBody(body io.Reader) (io.ReadCloser, int64, error) {
+	file, err := os.CreateTemp("", "filesys-archive-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+	return &archiveTempFile{File: file}, size, nil
+}
+
+// archiveTempFile 打包时使用的临时文件，关闭时删除
+type archiveTempFile struct {
+	*os.File
+}
+
+func (f *archiveTempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.File.Name())
+	return err
+}
+
+// archiveEntryName 生成归档中的文件名，不允许跳出归档的根目录，重名时在扩展名前加序号
+func archiveEntryName(object string, opts *ArchiveOptions, names map[string]bool) string {
+	name := archiveName(object)
+	if prefix := archiveName(opts.StripPrefix); prefix != "" && strings.HasPrefix(name, prefix+"/") {
+		name = strings.TrimPrefix(name, prefix+"/")
+	}
+	if opts.Flatten {
+		name = path.Base(name)
+	}
+	unique := name
+	ext := path.Ext(name)
+	for i := 1; names[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	names[unique] = true
+	return unique
+}
+
+func newArchiveStream(format string, w io.Writer) (*archiveStream, error) {
+	switch format {
+	case "", ArchiveZip:
+		return &archiveStream{zw: zip.NewWriter(w)}, nil
+	case ArchiveTar:
+		return &archiveStream{tw: tar.NewWriter(w)}, nil
+	case ArchiveTarGz:
+		gw := gzip.NewWriter(w)
+		return &archiveStream{tw: tar.NewWriter(gw), gw: gw}, nil
+	}
+	return nil, gerror.Newf("归档格式[%s]不支持", format)
+}
+
+// write 写入一个文件，tar 需要提前知道文件大小，file.Size 必须是 body 的实际大小，实际内容不足时返回错误
+func (s *archiveStream) write(name string, file *File, body io.Reader) (err error) {
+	modTime := file.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if s.zw != nil {
+		var fw io.Writer
+		if fw, err = s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}); err != nil {
+			return
+		}
+		_, err = io.Copy(fw, body)
+		return
+	}
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: file.Size, ModTime: modTime}
+	if err = s.tw.WriteHeader(header); err != nil {
+		return
+	}
+	_, err = io.CopyN(s.tw, body, file.Size)
+	return
+}
+
+// close 写入归档的结尾，不关闭 w
+func (s *archiveStream) close() (err error) {
+	if s.zw != nil {
+		return s.zw.Close()
+	}
+	if err = s.tw.Close(); err != nil {
+		return
+	}
+	if s.gw != nil {
+		return s.gw.Close()
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package filesys
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+// staleListAdapter 列表中的文件大小与实际内容不一致，模拟列出后文件被修改
+type staleListAdapter struct {
+	*MemoryAdapter
+	delta int64
+}
+
+func (a *staleListAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (*ListResult, error) {
+	result, err := a.MemoryAdapter.ListPage(ctx, prefix, token, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range result.Files {
+		file.Size += a.delta
+	}
+	return result, nil
+}
+
+func TestDownloadArchiveTarStaleSize(t *testing.T) {
+	ctx := context.Background()
+	files := map[string]string{"dir/a.txt": "hello", "dir/b.txt": "world!"}
+	for _, delta := range []int64{-2, 3} {
+		memory := newMemoryAdapter(t, files)
+
+		var buf bytes.Buffer
+		failed, err := downloadArchive(ctx, &staleListAdapter{MemoryAdapter: memory, delta: delta},
+			&ArchiveOptions{Prefix: "dir", Format: ArchiveTar, StripPrefix: "dir"}, &buf)
+		if err != nil || len(failed) > 0 {
+			t.Fatalf("delta %d: downloadArchive: failed=%v, err=%v", delta, failed, err)
+		}
+
+		got := make(map[string]string)
+		tr := tar.NewReader(&buf)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("delta %d: read tar: %v", delta, err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("delta %d: read %s: %v", delta, header.Name, err)
+			}
+			got["dir/"+header.Name] = string(data)
+		}
+		if len(got) != len(files) {
+			t.Fatalf("delta %d: got %v, want %v", delta, got, files)
+		}
+		for name, content := range files {
+			if got[name] != content {
+				t.Errorf("delta %d: %s = %q, want %q", delta, name, got[name], content)
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package filesys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/glog"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 审计记录的结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord 审计记录
+type AuditRecord struct {
+	Time       time.Time `json:"time"`                 // 操作开始的时间
+	Actor      string    `json:"actor,omitempty"`      // 操作人
+	Adapter    string    `json:"adapter"`              // 适配器类型
+	Op         string    `json:"op"`                   // 操作，分片上传完成时记录为 Upload，分片上传失败时记录为 UploadPart
+	Object     string    `json:"object"`               // 文件路径，复制、移动时为源文件
+	Target     string    `json:"target,omitempty"`     // 复制、移动的目标路径
+	Size       int64     `json:"size"`                 // 上传的字节数，其他操作为0
+	Hash       string    `json:"hash,omitempty"`       // 上传内容的 sha256，分片上传不计算
+	Result     string    `json:"result"`               // AuditSuccess 或 AuditFailure
+	Error      string    `json:"error,omitempty"`      // 失败原因
+	ErrorClass string    `json:"errorClass,omitempty"` // 错误分类，例如 not_exist、permission
+}
+
+// AuditSink 审计记录的输出
+type AuditSink interface {
+	Write(ctx context.Context, record *AuditRecord) error
+}
+
+// AuditSinkFunc 把回调函数作为审计记录的输出
+type AuditSinkFunc func(ctx context.Context, record *AuditRecord) error
+
+func (f AuditSinkFunc) Write(ctx context.Context, record *AuditRecord) error {
+	return f(ctx, record)
+}
+
+// AuditConfig 审计配置
+type AuditConfig struct {
+	Sink     AuditSink                                                 // 审计记录的输出，必填
+	Actor    func(ctx context.Context) string                          // 从 ctx 获取操作人，为空时使用 WithAuditActor 设置的值
+	Adapter  string                                                    // adapter 字段的值，为空时根据适配器类型生成，例如 OssAdapter 为 oss
+	LogReads bool                                                      // 是否记录 Download、DownloadRange、GetSignURL
+	OnError  func(ctx context.Context, record *AuditRecord, err error) // 写入审计记录失败时回调，为空时使用 glog 记录错误
+}
+
+// auditActorKey ctx 中保存操作人的 key
+type auditActorKey struct{}
+
+// WithAuditActor 在 ctx 中设置操作人
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor 获取 WithAuditActor 设置的操作人
+func AuditActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+// NewAuditMiddleware 审计中间件，记录 Upload、Delete、Copy、Move、AbortMultipart 等修改操作，以及失败的 UploadPart，
+// 添加在重试中间件之外时每次操作只记录一次，添加在之内时每次重试都会记录
+func NewAuditMiddleware(cfg *AuditConfig) (Middleware, error) {
+	if cfg == nil || cfg.Sink == nil {
+		return nil, gerror.New("Sink不能为空")
+	}
+	config := *cfg
+	if config.Actor == nil {
+		config.Actor = AuditActor
+	}
+	if config.OnError == nil {
+		config.OnError = func(ctx context.Context, record *AuditRecord, err error) {
+			glog.Errorf(ctx, "写入审计记录失败: %v, op=%s, object=%s", err, record.Op, record.Object)
+		}
+	}
+	return func(next Adapter) Adapter {
+		name := config.Adapter
+		if name == "" {
+			name = adapterName(next)
+		}
+		return &auditAdapter{AdapterWrapper: AdapterWrapper{Next: next}, config: &config, adapter: name}
+	}, nil
+}
+
+// auditAdapter 审计中间件
+type auditAdapter struct {
+	AdapterWrapper
+	config  *AuditConfig
+	adapter string
+}
+
+// record 生成审计记录，操作完成后调用 write 输出
+func (a *auditAdapter) record(ctx context.Context, op, object string) *AuditRecord {
+	return &AuditRecord{
+		Time:    time.Now(),
+		Actor:   a.config.Actor(ctx),
+		Adapter: a.adapter,
+		Op:      op,
+		Object:  object,
+	}
+}
+
+// write 填写操作结果后输出审计记录
+func (a *auditAdapter) write(ctx context.Context, record *AuditRecord, err error) {
+	record.Result = AuditSuccess
+	if err != nil {
+		record.Result = AuditFailure
+		record.Error = err.Error()
+		record.ErrorClass = errorClass(err)
+	}
+	if errWrite := a.config.Sink.Write(ctx, record); errWrite != nil {
+		a.config.OnError(ctx, record, errWrite)
+	}
+}
+
+func (a *auditAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	record := a.record(ctx, "Upload", path)
+	hashReader := newAuditReader(reader)
+	err = a.Next.Upload(ctx, path, hashReader, size, headers...)
+	record.Size, record.Hash = hashReader.sum()
+	a.write(ctx, record, err)
+	return
+}
+
+// Delete 每个文件生成一条审计记录
+func (a *auditAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	records := make([]*AuditRecord, 0, len(objects))
+	for _, object := range objects {
+		records = append(records, a.record(ctx, "Delete", object))
+	}
+	err = a.Next.Delete(ctx, objects...)
+	for _, record := range records {
+		a.write(ctx, record, err)
+	}
+	return
+}
+
+func (a *auditAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	record := a.record(ctx, "Copy", src)
+	record.Target = dst
+	err = a.AdapterWrapper.Copy(ctx, src, dst)
+	a.write(ctx, record, err)
+	return
+}
+
+func (a *auditAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	record := a.record(ctx, "Move", src)
+	record.Target = dst
+	err = a.AdapterWrapper.Move(ctx, src, dst)
+	a.write(ctx, record, err)
+	return
+}
+
+// CompleteMultipart 分片上传完成时才生成文件，记录为 Upload
+func (a *auditAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	record := a.record(ctx, "Upload", object)
+	for _, part := range parts {
+		record.Size += part.Size
+	}
+	err = a.AdapterWrapper.CompleteMultipart(ctx, object, uploadID, parts)
+	a.write(ctx, record, err)
+	return
+}
+
+// UploadPart 分片上传成功时不生成文件，只记录失败的分片，分片上传和断点续传失败时也能留下审计记录
+func (a *auditAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	record := a.record(ctx, "UploadPart", object)
+	record.Size = size
+	if part, err = a.AdapterWrapper.UploadPart(ctx, object, uploadID, number, reader, size); err != nil {
+		a.write(ctx, record, err)
+	}
+	return
+}
+
+// AbortMultipart 取消分片上传会删除已上传的分片
+func (a *auditAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	record := a.record(ctx, "AbortMultipart", object)
+	err = a.AdapterWrapper.AbortMultipart(ctx, object, uploadID)
+	a.write(ctx, record, err)
+	return
+}
+
+func (a *auditAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	if !a.config.LogReads {
+		return a.Next.Download(ctx, object)
+	}
+	record := a.record(ctx, "Download", object)
+	body, err = a.Next.Download(ctx, object)
+	a.write(ctx, record, err)
+	return
+}
+
+func (a *auditAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	if !a.config.LogReads {
+		return a.AdapterWrapper.DownloadRange(ctx, object, offset, length)
+	}
+	record := a.record(ctx, "DownloadRange", object)
+	body, err = a.AdapterWrapper.DownloadRange(ctx, object, offset, length)
+	a.write(ctx, record, err)
+	return
+}
+
+func (a *auditAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	if !a.config.LogReads {
+		return a.Next.GetSignURL(ctx, object, expire...)
+	}
+	record := a.record(ctx, "GetSignURL", object)
+	link, err = a.Next.GetSignURL(ctx, object, expire...)
+	a.write(ctx, record, err)
+	return
+}
+
+// auditReader 上传时计算已读取内容的字节数和 sha256
+type auditReader struct {
+	reader io.Reader
+	hash   hash.Hash
+	size   int64
+}
+
+// auditReadSeeker 原始 reader 可以 Seek 时保留 Seek，回到开始位置时重新计算，其他位置的 Seek 无法计算哈希
+type auditReadSeeker struct {
+	*auditReader
+	seeker  io.Seeker
+	start   int64
+	invalid bool
+}
+
+// auditHashReader 上传内容的字节数和哈希
+type auditHashReader interface {
+	io.Reader
+	sum() (size int64, hash string)
+}
+
+func newAuditReader(reader io.Reader) auditHashReader {
+	r := &auditReader{reader: reader, hash: sha256.New()}
+	if seeker, ok := reader.(io.Seeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return &auditReadSeeker{auditReader: r, seeker: seeker, start: start}
+		}
+	}
+	return r
+}
+
+func (r *auditReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	r.hash.Write(p[:n])
+	r.size += int64(n)
+	return
+}
+
+func (r *auditReader) sum() (int64, string) {
+	return r.size, hex.EncodeToString(r.hash.Sum(nil))
+}
+
+func (r *auditReadSeeker) Seek(offset int64, whence int) (pos int64, err error) {
+	if pos, err = r.seeker.Seek(offset, whence); err != nil {
+		return
+	}
+	if pos == r.start {
+		r.hash.Reset()
+		r.size = 0
+		r.invalid = false
+	} else {
+		r.invalid = true
+	}
+	return
+}
+
+func (r *auditReadSeeker) sum() (int64, string) {
+	if r.invalid {
+		return r.size, ""
+	}
+	return r.auditReader.sum()
+}
+
+// JSONAuditSink 以 JSON Lines 格式输出审计记录，并发安全
+type JSONAuditSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONAuditSink 把审计记录写入 w，每条记录一行
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{w: w}
+}
+
+// NewJSONFileAuditSink 把审计记录追加到文件，文件不存在时创建，使用后需要 Close
+func NewJSONFileAuditSink(name string) (*JSONAuditSink, error) {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONAuditSink{w: file, closer: file}, nil
+}
+
+func (s *JSONAuditSink) Write(ctx context.Context, record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close 关闭 NewJSONFileAuditSink 打开的文件
+func (s *JSONAuditSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// glogAuditSink 使用 glog 输出审计记录
+type glogAuditSink struct {
+	logger *glog.Logger
+}
+
+// NewGlogAuditSink 使用 glog 输出审计记录，成功的操作为 Info 级别，失败的为 Warning 级别，logger 为空时使用默认的 logger
+func NewGlogAuditSink(logger *glog.Logger) AuditSink {
+	if logger == nil {
+		logger = glog.DefaultLogger()
+	}
+	return &glogAuditSink{logger: logger}
+}
+
+func (s *glogAuditSink) Write(ctx context.Context, record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if record.Result == AuditFailure {
+		s.logger.Warning(ctx, "[filesys audit]", string(data))
+	} else {
+		s.logger.Info(ctx, "[filesys audit]", string(data))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// auditRecorder 保存审计记录的输出
+type auditRecorder struct {
+	mu      sync.Mutex
+	records []*AuditRecord
+}
+
+func (r *auditRecorder) Write(ctx context.Context, record *AuditRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	return nil
+}
+
+// take 返回并清空已保存的审计记录
+func (r *auditRecorder) take() []*AuditRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := r.records
+	r.records = nil
+	return records
+}
+
+func newAuditStore(t *testing.T, adapter Adapter, logReads bool) (*Store, *auditRecorder) {
+	recorder := &auditRecorder{}
+	mw, err := NewAuditMiddleware(&AuditConfig{Sink: recorder, LogReads: logReads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewWithAdapter(adapter)
+	store.Use(mw)
+	return store, recorder
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	ctx := WithAuditActor(context.Background(), "alice")
+	store, recorder := newAuditStore(t, newMemoryAdapter(t, map[string]string{"b.txt": "b"}), false)
+
+	if err := store.Upload(ctx, "a.txt", strings.NewReader("hello"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Copy(ctx, "a.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Move(ctx, "missing.txt", "d.txt"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("Move missing file: got %v, want ErrNotExist", err)
+	}
+	if err := store.Deletes(ctx, []string{"a.txt", "b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	// 未开启 LogReads 时不记录读取操作
+	if _, err := store.GetSignURL(ctx, "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AuditRecord{
+		{Op: "Upload", Object: "a.txt", Size: 5, Hash: sha256Hex("hello"), Result: AuditSuccess},
+		{Op: "Copy", Object: "a.txt", Target: "c.txt", Result: AuditSuccess},
+		{Op: "Move", Object: "missing.txt", Target: "d.txt", Result: AuditFailure, ErrorClass: "not_exist"},
+		{Op: "Delete", Object: "a.txt", Result: AuditSuccess},
+		{Op: "Delete", Object: "b.txt", Result: AuditSuccess},
+	}
+	records := recorder.take()
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, record := range records {
+		if record.Time.IsZero() || record.Actor != "alice" || record.Adapter != "memory" || (record.Error == "") != (want[i].Result == AuditSuccess) {
+			t.Errorf("record %d = %+v", i, record)
+		}
+		record.Time, record.Actor, record.Adapter, record.Error = want[i].Time, "", "", ""
+		if *record != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, *record, want[i])
+		}
+	}
+}
+
+func TestAuditMiddlewareReads(t *testing.T) {
+	ctx := context.Background()
+	store, recorder := newAuditStore(t, newMemoryAdapter(t, map[string]string{"a.txt": "abc"}), true)
+	body, err := store.Download(ctx, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	if body, err = store.DownloadRange(ctx, "a.txt", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+	if _, err = store.GetSignURL(ctx, "a.txt", 60); err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, record := range recorder.take() {
+		ops = append(ops, record.Op)
+	}
+	if got, want := strings.Join(ops, ","), "Download,DownloadRange,GetSignURL"; got != want {
+		t.Errorf("ops = %s, want %s", got, want)
+	}
+}
+
+func TestAuditMiddlewareMultipart(t *testing.T) {
+	ctx := context.Background()
+	adapter := newFaultyMemoryAdapter(t, 0)
+	store, recorder := newAuditStore(t, adapter, false)
+	store.SetMultipartConfig(MultipartConfig{PartSize: 4, Concurrency: 1})
+
+	// 分片上传完成时记录为一次 Upload
+	if err := store.MultipartUpload(ctx, "a.bin", bytes.NewReader(testData(10)), 10); err != nil {
+		t.Fatal(err)
+	}
+	records := recorder.take()
+	if len(records) != 1 || records[0].Op != "Upload" || records[0].Size != 10 || records[0].Result != AuditSuccess {
+		t.Fatalf("records = %+v", records)
+	}
+
+	// 分片上传失败时记录失败的分片和取消
+	adapter.failPart = 2
+	if err := store.MultipartUpload(ctx, "b.bin", bytes.NewReader(testData(10)), 10); !errors.Is(err, adapter.err) {
+		t.Fatalf("MultipartUpload = %v, want %v", err, adapter.err)
+	}
+	records = recorder.take()
+	if len(records) != 2 ||
+		records[0].Op != "UploadPart" || records[0].Object != "b.bin" || records[0].Result != AuditFailure ||
+		records[1].Op != "AbortMultipart" || records[1].Object != "b.bin" || records[1].Result != AuditSuccess {
+		t.Fatalf("records = %+v", records)
+	}
+}
+
+func TestAuditMiddlewareSinkError(t *testing.T) {
+	sinkErr := errors.New("sink failed")
+	var failed []*AuditRecord
+	mw, err := NewAuditMiddleware(&AuditConfig{
+		Sink: AuditSinkFunc(func(ctx context.Context, record *AuditRecord) error {
+			return sinkErr
+		}),
+		OnError: func(ctx context.Context, record *AuditRecord, err error) {
+			if !errors.Is(err, sinkErr) {
+				t.Errorf("OnError(%v)", err)
+			}
+			failed = append(failed, record)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := mw(newMemoryAdapter(t, nil))
+	// 写入审计记录失败不影响操作结果
+	if err = adapter.Upload(context.Background(), "a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Op != "Upload" {
+		t.Fatalf("OnError records = %+v", failed)
+	}
+
+	if _, err = NewAuditMiddleware(&AuditConfig{}); err == nil {
+		t.Fatal("NewAuditMiddleware without Sink: want error")
+	}
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONAuditSink(&buf)
+	records := []*AuditRecord{
+		{Op: "Upload", Object: "a.txt", Result: AuditSuccess},
+		{Op: "Delete", Object: "b.txt", Result: AuditFailure, ErrorClass: "not_exist"},
+	}
+	for _, record := range records {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(records) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(records))
+	}
+	for i, line := range lines {
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record != *records[i] {
+			t.Errorf("line %d = %s, %v", i, line, err)
+		}
+	}
+}
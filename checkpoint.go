@@ -0,0 +1,114 @@
+package filesys
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/os/gfile"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checkpoint 断点续传的上传进度
+type Checkpoint struct {
+	ID        string            `json:"id"`        // 断点ID
+	Object    string            `json:"object"`    // 上传的文件路径
+	UploadID  string            `json:"uploadId"`  // 分片上传ID
+	Size      int64             `json:"size"`      // 文件大小，-1表示未知
+	PartSize  int64             `json:"partSize"`  // 分片大小，续传时必须和首次上传保持一致，大小未知时分片数接近上限后会增大
+	Headers   map[string]string `json:"headers"`   // 上传时的header
+	Parts     []*Part           `json:"parts"`     // 已上传成功的分片
+	CreatedAt time.Time         `json:"createdAt"` // 开始上传的时间
+	UpdatedAt time.Time         `json:"updatedAt"` // 最后一次更新进度的时间
+}
+
+// CheckpointStore 断点续传进度的存储，可以替换成数据库、redis等实现
+type CheckpointStore interface {
+	// Save 保存上传进度，已存在时覆盖
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+	// Load 读取上传进度，不存在时返回 ErrNotExist
+	Load(ctx context.Context, id string) (*Checkpoint, error)
+	// Delete 删除上传进度
+	Delete(ctx context.Context, id string) error
+}
+
+// FileCheckpointStore 把上传进度以JSON文件的形式保存在本地目录
+type FileCheckpointStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileCheckpointStore 实例化本地文件的断点存储，dir为空时使用系统临时目录
+func NewFileCheckpointStore(dir string) *FileCheckpointStore {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "filesys-checkpoint")
+	}
+	return &FileCheckpointStore{dir: dir}
+}
+
+func (s *FileCheckpointStore) Save(ctx context.Context, checkpoint *Checkpoint) (err error) {
+	var (
+		file string
+		data []byte
+	)
+	if file, err = s.file(checkpoint.ID); err != nil {
+		return
+	}
+	if data, err = json.Marshal(checkpoint); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return
+	}
+	// 先写临时文件再重命名，避免进程中断时留下不完整的进度文件
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *FileCheckpointStore) Load(ctx context.Context, id string) (checkpoint *Checkpoint, err error) {
+	var (
+		file string
+		data []byte
+	)
+	if file, err = s.file(id); err != nil {
+		return
+	}
+	s.mu.Lock()
+	data, err = os.ReadFile(file)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint = &Checkpoint{}
+	err = json.Unmarshal(data, checkpoint)
+	return
+}
+
+func (s *FileCheckpointStore) Delete(ctx context.Context, id string) (err error) {
+	var file string
+	if file, err = s.file(id); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !gfile.Exists(file) {
+		return
+	}
+	return os.Remove(file)
+}
+
+// file 进度文件路径，id不能包含路径分隔符
+func (s *FileCheckpointStore) file(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", &storeError{kind: ErrInvalidPath, err: gerror.Newf("断点ID[%s]不合法", id)}
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
@@ -0,0 +1,55 @@
+package filesys
+
+import (
+	"context"
+	"strings"
+)
+
+// copyHeaderKeys 复制文件时需要保留的header
+var copyHeaderKeys = []string{"content-type", "content-encoding", "content-disposition"}
+
+// copyObject 复制文件，适配器不支持服务端复制时，下载后重新上传
+func copyObject(ctx context.Context, adapter Adapter, src, dst string) (err error) {
+	if copier, ok := adapter.(Copier); ok {
+		return copier.Copy(ctx, src, dst)
+	}
+
+	info, err := adapter.GetInfo(ctx, src)
+	if err != nil {
+		return
+	}
+	body, err := adapter.Download(ctx, src)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	return adapter.Upload(ctx, dst, body, info.Size, copyHeaders(info.Header))
+}
+
+// moveObject 移动文件，适配器不支持服务端移动时，复制后删除源文件，
+// 源文件和目标文件相同时只检查文件是否存在，避免复制后删除源文件
+func moveObject(ctx context.Context, adapter Adapter, src, dst string) (err error) {
+	if objectRel(src) == objectRel(dst) {
+		return adapter.IsExist(ctx, src)
+	}
+	if mover, ok := adapter.(Mover); ok {
+		return mover.Move(ctx, src, dst)
+	}
+	if err = copyObject(ctx, adapter, src, dst); err != nil {
+		return
+	}
+	return adapter.Delete(ctx, src)
+}
+
+// copyHeaders 从文件信息中挑选出复制时需要保留的header
+func copyHeaders(header map[string]string) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range header {
+		for _, key := range copyHeaderKeys {
+			if strings.ToLower(k) == key && v != "" {
+				headers[k] = v
+			}
+		}
+	}
+	return headers
+}
@@ -0,0 +1,46 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// copyOnlyAdapter 只支持服务端复制的内存适配器，模拟没有 Move 的对象存储
+type copyOnlyAdapter struct {
+	Adapter
+	memory *MemoryAdapter
+}
+
+func (a *copyOnlyAdapter) Copy(ctx context.Context, src, dst string) error {
+	return a.memory.Copy(ctx, src, dst)
+}
+
+func TestMoveObject(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, map[string]string{"a.txt": "a"})
+	adapter := &copyOnlyAdapter{Adapter: memory, memory: memory}
+
+	// 移动到自身时不删除源文件
+	for _, dst := range []string{"a.txt", "/a.txt", "./a.txt"} {
+		if err := moveObject(ctx, adapter, "a.txt", dst); err != nil {
+			t.Fatalf("Move(a.txt, %s): %v", dst, err)
+		}
+		if got := readObject(t, memory, "a.txt"); got != "a" {
+			t.Fatalf("a.txt = %q after Move(a.txt, %s)", got, dst)
+		}
+	}
+	if err := moveObject(ctx, adapter, "missing.txt", "/missing.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Move missing file onto itself: got %v, want ErrNotExist", err)
+	}
+
+	if err := moveObject(ctx, adapter, "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, memory, "b.txt"); got != "a" {
+		t.Errorf("b.txt = %q after Move", got)
+	}
+	if err := memory.IsExist(ctx, "a.txt"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("IsExist(a.txt) after Move: got %v, want ErrNotExist", err)
+	}
+}
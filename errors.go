@@ -0,0 +1,158 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// 统一的错误类型，各适配器会把驱动返回的错误归类到以下类型，调用方可以通过 errors.Is 判断
+var (
+	ErrNotExist      = fs.ErrNotExist          // 文件不存在
+	ErrPermission    = fs.ErrPermission        // 没有访问权限
+	ErrAlreadyExists = fs.ErrExist             // 文件已存在
+	ErrInvalidPath   = fs.ErrInvalid           // 文件路径不合法
+	ErrUnsupported   = errors.New("适配器不支持该操作") // 适配器不支持该操作
+)
+
+// storeError 保留驱动原始错误的同时，标记其所属的错误类型
+type storeError struct {
+	kind      error
+	err       error
+	temporary bool // 服务端返回的临时错误，重试可能成功
+}
+
+func (e *storeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *storeError) Unwrap() error {
+	return e.err
+}
+
+func (e *storeError) Is(target error) bool {
+	return target == e.kind
+}
+
+// wrapError 把 err 标记为 kind 类型，err 已经是该类型时原样返回
+func wrapError(kind, err error) error {
+	if err == nil || kind == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &storeError{kind: kind, err: err}
+}
+
+// errorKindByStatus 根据HTTP状态码判断错误类型
+func errorKindByStatus(status int) error {
+	switch status {
+	case http.StatusNotFound:
+		return ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrPermission
+	case http.StatusConflict:
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// statusError 根据HTTP状态码对错误进行归类，超时、限流和服务端错误标记为临时错误
+func statusError(status int, err error) error {
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return temporaryError(err)
+	}
+	return wrapError(errorKindByStatus(status), err)
+}
+
+// temporaryError 把 err 标记为可以重试的临时错误
+func temporaryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &storeError{err: err, temporary: true}
+}
+
+// notExistError 生成文件不存在的错误
+func notExistError(object string) error {
+	return &storeError{kind: ErrNotExist, err: gerror.Newf("文件[%s]不存在", object)}
+}
+
+// invalidPathError 生成文件路径不合法的错误
+func invalidPathError(object string) error {
+	return &storeError{kind: ErrInvalidPath, err: gerror.Newf("文件路径[%s]不合法", object)}
+}
+
+// joinedError 合并多个错误，errors.Is 和 errors.As 对其中任意一个错误成立时即成立
+type joinedError struct {
+	errs []error
+}
+
+func (e *joinedError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *joinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *joinedError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors 合并已经归类的错误，忽略其中的 nil，只有一个错误时原样返回
+func joinErrors(errs ...error) error {
+	var nonNil []error
+	for _, err := range errs {
+		if err != nil {
+			nonNil = append(nonNil, err)
+		}
+	}
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return nonNil[0]
+	}
+	return &joinedError{errs: nonNil}
+}
+
+// errorClass 错误的分类，用于监控和日志
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrNotExist):
+		return "not_exist"
+	case errors.Is(err, ErrPermission):
+		return "permission"
+	case errors.Is(err, ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, ErrInvalidPath):
+		return "invalid_path"
+	case errors.Is(err, ErrUnsupported):
+		return "unsupported"
+	case IsRetryable(err):
+		return "temporary"
+	}
+	return "other"
+}
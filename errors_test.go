@@ -0,0 +1,46 @@
+package filesys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJoinErrors(t *testing.T) {
+	notExist := notExistError("a.txt")
+	temporary := temporaryError(errors.New("503 service unavailable"))
+	permission := wrapError(ErrPermission, errors.New("403 forbidden"))
+
+	tests := []struct {
+		name      string
+		errs      []error
+		nilErr    bool
+		is        []error
+		class     string
+		retryable bool
+	}{
+		{name: "empty", errs: []error{nil, nil}, nilErr: true},
+		{name: "single", errs: []error{nil, notExist}, is: []error{ErrNotExist}, class: "not_exist"},
+		{name: "not exist and permission", errs: []error{notExist, permission}, is: []error{ErrNotExist, ErrPermission}, class: "not_exist"},
+		{name: "temporary", errs: []error{notExist, temporary}, is: []error{ErrNotExist}, class: "not_exist", retryable: true},
+		{name: "only temporary", errs: []error{temporary, temporary}, class: "temporary", retryable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := joinErrors(tt.errs...)
+			if (err == nil) != tt.nilErr {
+				t.Fatalf("joinErrors = %v, want nil %v", err, tt.nilErr)
+			}
+			for _, kind := range tt.is {
+				if !errors.Is(err, kind) {
+					t.Errorf("errors.Is(%v, %v) = false", err, kind)
+				}
+			}
+			if class := errorClass(err); class != tt.class {
+				t.Errorf("errorClass = %q, want %q", class, tt.class)
+			}
+			if retryable := IsRetryable(err); retryable != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", retryable, tt.retryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// StoreFS 把存储器包装为只读的 io/fs 文件系统，可以用于 http.FS、template.ParseFS、fs.WalkDir 等
+type StoreFS interface {
+	fs.StatFS
+	fs.ReadDirFS
+	fs.ReadFileFS
+}
+
+// storeFS 基于 Download、GetInfo 和 ListDir 实现，对象存储中没有占位对象的目录根据文件前缀合成
+type storeFS struct {
+	ctx     context.Context
+	adapter Adapter
+}
+
+// FS 返回只读的 io/fs 文件系统，ctx 用于文件系统的全部请求
+func (c *Store) FS(ctx context.Context) StoreFS {
+	return &storeFS{ctx: ctx, adapter: c.localAdapter}
+}
+
+func (s *storeFS) Open(name string) (fs.File, error) {
+	info, err := s.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &storeDir{fsys: s, name: name, info: info}, nil
+	}
+	return &storeFile{fsys: s, name: name, info: info}, nil
+}
+
+func (s *storeFS) Stat(name string) (fs.FileInfo, error) {
+	return s.stat("stat", name)
+}
+
+func (s *storeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := s.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return s.readDir(name)
+}
+
+func (s *storeFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	body, err := s.adapter.Download(s.ctx, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// stat 获取文件信息，文件不存在时判断是否为目录，对象存储中目录下有文件即视为目录存在
+func (s *storeFS) stat(op, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{file: dirFile("")}, nil
+	}
+	file, err := s.adapter.GetInfo(s.ctx, name)
+	if err == nil {
+		return &fileInfo{file: file}, nil
+	}
+	if !errors.Is(err, ErrNotExist) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	res, err := listPage(s.ctx, s.adapter, dirPrefix(name), "", 1)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if len(res.Files) == 0 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return &fileInfo{file: dirFile(name)}, nil
+}
+
+// readDir 列出目录下的文件和子目录，按文件名排序
+func (s *storeFS) readDir(name string) ([]fs.DirEntry, error) {
+	dir := name
+	if dir == "." {
+		dir = ""
+	}
+	files, err := listDir(s.ctx, s.adapter, dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, 0, len(files))
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		info := &fileInfo{file: file}
+		// 跳过同时存在占位对象和文件前缀时重复的目录
+		if info.Name() == "." || seen[info.Name()] {
+			continue
+		}
+		seen[info.Name()] = true
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// fileInfo 把 File 转换为 fs.FileInfo
+type fileInfo struct {
+	file *File
+}
+
+func (i *fileInfo) Name() string {
+	name := path.Base("/" + objectRel(i.file.Name))
+	if name == "/" {
+		return "."
+	}
+	return name
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.file.Size
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.file.ModTime
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.file.IsDir || isDirKey(i.file.Name)
+}
+
+// Sys 返回原始的 *File
+func (i *fileInfo) Sys() interface{} {
+	return i.file
+}
+
+// storeFile 只读文件，第一次读取时才开始下载，Seek 后从新的位置重新下载
+type storeFile struct {
+	fsys   *storeFS
+	name   string
+	info   fs.FileInfo
+	body   io.ReadCloser
+	offset int64
+	closed bool
+}
+
+func (f *storeFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *storeFile) Read(p []byte) (n int, err error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if size := f.info.Size(); size > 0 && f.offset >= size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		if f.body, err = downloadRange(f.fsys.ctx, f.fsys.adapter, f.name, f.offset, 0); err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+	}
+	n, err = f.body.Read(p)
+	f.offset += int64(n)
+	return
+}
+
+func (f *storeFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *storeFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// storeDir 只读目录，第一次调用 ReadDir 时列出目录
+type storeDir struct {
+	fsys    *storeFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *storeDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *storeDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *storeDir) ReadDir(n int) (entries []fs.DirEntry, err error) {
+	if !d.listed {
+		if d.entries, err = d.fsys.readDir(d.name); err != nil {
+			return
+		}
+		d.listed = true
+	}
+	if n <= 0 {
+		entries, d.entries = d.entries, nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries, d.entries = d.entries[:n], d.entries[n:]
+	return entries, nil
+}
+
+func (d *storeDir) Close() error {
+	return nil
+}
@@ -6,14 +6,19 @@ import (
 )
 
 const (
-	TypeBos   = "bos"   //百度云存储
-	TypeCos   = "cos"   //腾讯云存储
-	TypeLocal = "local" //本地
-	TypeMinio = "minio" //minio存储
-	TypeObs   = "obs"   //华为云存储
-	TypeOss   = "oss"   //阿里云存储
-	TypeQiniu = "qiniu" //七牛云储存
-	TypeUpyun = "upyun" //又拍云存储
+	TypeBos    = "bos"    //百度云存储
+	TypeCos    = "cos"    //腾讯云存储
+	TypeFtp    = "ftp"    //FTP服务器
+	TypeLocal  = "local"  //本地
+	TypeMemory = "memory" //内存存储，用于测试
+	TypeMinio  = "minio"  //minio存储
+	TypeObs    = "obs"    //华为云存储
+	TypeOss    = "oss"    //阿里云存储
+	TypeQiniu  = "qiniu"  //七牛云储存
+	TypeS3     = "s3"     //S3兼容存储
+	TypeSftp   = "sftp"   //SFTP服务器
+	TypeUpyun  = "upyun"  //又拍云存储
+	TypeWebdav = "webdav" //WebDAV服务器
 )
 
 type NewAdapter func(i interface{}) (Adapter, error)
@@ -21,14 +26,19 @@ type NewAdapter func(i interface{}) (Adapter, error)
 var (
 	defaultStore *Store // 默认文件存储器
 	adapters     = map[string]NewAdapter{
-		TypeBos:   NewAdapterBos,
-		TypeCos:   NewAdapterCos,
-		TypeLocal: NewAdapterLocal,
-		TypeMinio: NewAdapterMinio,
-		TypeObs:   NewAdapterObs,
-		TypeOss:   NewAdapterOss,
-		TypeQiniu: NewAdapterQiniu,
-		TypeUpyun: NewAdapterUpYun,
+		TypeBos:    NewAdapterBos,
+		TypeCos:    NewAdapterCos,
+		TypeFtp:    NewAdapterFtp,
+		TypeLocal:  NewAdapterLocal,
+		TypeMemory: NewAdapterMemory,
+		TypeMinio:  NewAdapterMinio,
+		TypeObs:    NewAdapterObs,
+		TypeOss:    NewAdapterOss,
+		TypeQiniu:  NewAdapterQiniu,
+		TypeS3:     NewAdapterS3,
+		TypeSftp:   NewAdapterSftp,
+		TypeUpyun:  NewAdapterUpYun,
+		TypeWebdav: NewAdapterWebdav,
 	}
 )
 
@@ -0,0 +1,166 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// defaultListLimit 分页列出文件时每页的默认数量
+const defaultListLimit = 1000
+
+// errStopWalk 用于提前结束遍历
+var errStopWalk = errors.New("stop walk")
+
+// listPage 分页列出文件，适配器不支持分页时，先列出全部文件再按文件名截取
+func listPage(ctx context.Context, adapter Adapter, prefix, token string, limit int) (result *ListResult, err error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if lister, ok := adapter.(PageLister); ok {
+		return lister.ListPage(ctx, prefix, token, limit)
+	}
+
+	var files []*File
+	if files, err = adapter.Lists(ctx, prefix); err != nil {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	start := sort.Search(len(files), func(i int) bool {
+		return files[i].Name > token
+	})
+	end := start + limit
+	if end > len(files) {
+		end = len(files)
+	}
+	result = &ListResult{Files: files[start:end]}
+	if end < len(files) {
+		result.NextToken = files[end-1].Name
+	}
+	return
+}
+
+// listAll 通过分页接口列出全部文件
+func listAll(ctx context.Context, lister PageLister, prefix string) (files []*File, err error) {
+	token := ""
+	for {
+		var res *ListResult
+		if res, err = lister.ListPage(ctx, prefix, token, defaultListLimit); err != nil {
+			return nil, err
+		}
+		files = append(files, res.Files...)
+		if res.NextToken == "" {
+			return
+		}
+		token = res.NextToken
+	}
+}
+
+// walkFiles 逐页遍历文件，fn 返回错误时停止遍历并返回该错误
+func walkFiles(ctx context.Context, adapter Adapter, prefix string, fn func(file *File) error) (err error) {
+	token := ""
+	for {
+		var res *ListResult
+		if res, err = listPage(ctx, adapter, prefix, token, defaultListLimit); err != nil {
+			return
+		}
+		for _, file := range res.Files {
+			if err = fn(file); err != nil {
+				return
+			}
+		}
+		if res.NextToken == "" {
+			return
+		}
+		token = res.NextToken
+	}
+}
+
+// listDir 按目录列出文件，适配器不支持时，遍历前缀下的全部文件再合成子目录
+func listDir(ctx context.Context, adapter Adapter, prefix string) (files []*File, err error) {
+	if lister, ok := adapter.(DirLister); ok {
+		return lister.ListDir(ctx, prefix)
+	}
+
+	prefix = dirPrefix(prefix)
+	dirs := make(map[string]bool)
+	err = walkFiles(ctx, adapter, prefix, func(file *File) error {
+		name := objectRel(file.Name)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		idx := strings.Index(name[len(prefix):], "/")
+		if idx < 0 {
+			files = append(files, file)
+			return nil
+		}
+		dir := name[:len(prefix)+idx]
+		if !dirs[dir] {
+			dirs[dir] = true
+			files = append(files, dirFile(dir))
+		}
+		return nil
+	})
+	return
+}
+
+// listDirPages 以 "/" 为分隔符逐页列出目录下的文件和子目录
+func listDirPages(ctx context.Context, prefix string, list func(ctx context.Context, prefix, delimiter, token string, limit int) (*ListResult, error)) (files []*File, err error) {
+	prefix = dirPrefix(prefix)
+	self := strings.TrimSuffix(prefix, "/")
+	token := ""
+	for {
+		var res *ListResult
+		if res, err = list(ctx, prefix, "/", token, defaultListLimit); err != nil {
+			return nil, err
+		}
+		for _, file := range res.Files {
+			// 跳过目录自身的占位对象
+			if strings.TrimSuffix(file.Name, "/") == self {
+				continue
+			}
+			files = append(files, file)
+		}
+		if res.NextToken == "" {
+			return
+		}
+		token = res.NextToken
+	}
+}
+
+// dirPrefix 把目录转换为以 "/" 结尾的前缀，根目录为空
+func dirPrefix(dir string) string {
+	dir = strings.TrimRight(objectRel(dir), "/")
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// dirFile 根据目录前缀生成目录信息
+func dirFile(prefix string) *File {
+	return &File{
+		Name:   strings.TrimSuffix(objectRel(prefix), "/"),
+		IsDir:  true,
+		Header: map[string]string{},
+	}
+}
+
+// isDirKey 以 "/" 结尾的对象是目录占位对象
+func isDirKey(key string) bool {
+	return strings.HasSuffix(key, "/")
+}
+
+// nextMarker 获取下一页的起始标记，服务端未返回 NextMarker 时以当前页最后一个文件作为起点
+func nextMarker(truncated bool, marker, lastKey string) string {
+	if !truncated {
+		return ""
+	}
+	if marker != "" {
+		return marker
+	}
+	return lastKey
+}
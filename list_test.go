@@ -0,0 +1,177 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// plainAdapter 只暴露 Adapter 接口，用于测试不支持可选接口时的降级
+type plainAdapter struct {
+	Adapter
+}
+
+func TestListPage(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, map[string]string{"a/1": "", "a/2": "", "a/b/3": "", "b": "", "c": ""})
+	all := []string{"a/1", "a/2", "a/b/3", "b", "c"}
+	adapters := map[string]Adapter{"PageLister": memory, "Lists": &plainAdapter{memory}}
+	for name, adapter := range adapters {
+		tests := []struct {
+			prefix string
+			limit  int
+			want   [][]string
+		}{
+			{"", 2, [][]string{all[:2], all[2:4], all[4:]}},
+			{"", 5, [][]string{all}},
+			{"", 0, [][]string{all}},
+			{"a/", 2, [][]string{all[:2], all[2:3]}},
+			{"x", 2, [][]string{nil}},
+		}
+		for _, tt := range tests {
+			var (
+				got   [][]string
+				token string
+			)
+			for len(got) <= len(tt.want) {
+				result, err := listPage(ctx, adapter, tt.prefix, token, tt.limit)
+				if err != nil {
+					t.Fatalf("%s: listPage(%q): %v", name, tt.prefix, err)
+				}
+				got = append(got, fileNames(result.Files))
+				if token = result.NextToken; token == "" {
+					break
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: listPage(%q, limit %d) = %v, want %v", name, tt.prefix, tt.limit, got, tt.want)
+			}
+		}
+
+		files, err := listAll(ctx, &plainAdapterLister{adapter}, "a")
+		if got := fileNames(files); err != nil || !reflect.DeepEqual(got, all[:3]) {
+			t.Errorf("%s: listAll(a) = %v, %v", name, got, err)
+		}
+	}
+}
+
+func TestListPageDefaultLimit(t *testing.T) {
+	ctx := context.Background()
+	files := map[string]string{"a/1": "1", "a/2": "2", "b": "b"}
+	local, err := NewAdapterLocal(ConfigLocal{Path: t.TempDir(), IsDev: "true", Domain: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapFS := fstest.MapFS{}
+	for name, content := range files {
+		if err = local.Upload(ctx, name, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+		mapFS[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	fsAdapter, err := NewAdapterFS(mapFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 直接调用适配器的 ListPage，limit 不大于0时使用默认数量
+	adapters := map[string]Adapter{"memory": newMemoryAdapter(t, files), "local": local, "fs": fsAdapter}
+	for name, adapter := range adapters {
+		for _, limit := range []int{0, -1} {
+			result, err := adapter.(PageLister).ListPage(ctx, "", "", limit)
+			if err != nil {
+				t.Fatalf("%s: ListPage(limit %d): %v", name, limit, err)
+			}
+			if got := fileNames(result.Files); len(got) != len(files) || result.NextToken != "" {
+				t.Errorf("%s: ListPage(limit %d) = %v, next %q", name, limit, got, result.NextToken)
+			}
+		}
+	}
+}
+
+// plainAdapterLister 通过 listPage 为任意适配器提供 PageLister
+type plainAdapterLister struct {
+	Adapter
+}
+
+func (a *plainAdapterLister) ListPage(ctx context.Context, prefix, token string, limit int) (*ListResult, error) {
+	// 每页一个文件，测试多页合并
+	return listPage(ctx, a.Adapter, prefix, token, 1)
+}
+
+func TestWalkFiles(t *testing.T) {
+	ctx := context.Background()
+	files := make(map[string]string)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		files[name] = name
+	}
+	memory := newMemoryAdapter(t, files)
+
+	var got []string
+	if err := walkFiles(ctx, memory, "", func(file *File) error {
+		got = append(got, file.Name)
+		return nil
+	}); err != nil || len(got) != 5 {
+		t.Fatalf("walkFiles = %v, %v", got, err)
+	}
+
+	// fn 返回错误时停止遍历
+	got = nil
+	err := walkFiles(ctx, memory, "", func(file *File) error {
+		got = append(got, file.Name)
+		if file.Name == "c" {
+			return errStopWalk
+		}
+		return nil
+	})
+	if !errors.Is(err, errStopWalk) || !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("walkFiles with errStopWalk = %v, %v", got, err)
+	}
+}
+
+func TestListDir(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(t, map[string]string{
+		"a/1": "", "a/2": "", "a/b/3": "", "a/b/4": "", "a/c/": "", "a0": "", "b": "",
+	})
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a/", "a0", "b"}},
+		{"a", []string{"a/1", "a/2", "a/b/", "a/c/"}},
+		{"/a/", []string{"a/1", "a/2", "a/b/", "a/c/"}},
+		{"a/b", []string{"a/b/3", "a/b/4"}},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		for name, adapter := range map[string]Adapter{"DirLister": memory, "walk": &plainAdapter{memory}} {
+			files, err := listDir(ctx, adapter, tt.prefix)
+			if err != nil {
+				t.Fatalf("%s: listDir(%q): %v", name, tt.prefix, err)
+			}
+			if got := fileNames(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: listDir(%q) = %v, want %v", name, tt.prefix, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestNextMarker(t *testing.T) {
+	tests := []struct {
+		truncated       bool
+		marker, lastKey string
+		want            string
+	}{
+		{false, "m", "k", ""},
+		{true, "m", "k", "m"},
+		{true, "", "k", "k"},
+	}
+	for _, tt := range tests {
+		if got := nextMarker(tt.truncated, tt.marker, tt.lastKey); got != tt.want {
+			t.Errorf("nextMarker(%v, %q, %q) = %q, want %q", tt.truncated, tt.marker, tt.lastKey, got, tt.want)
+		}
+	}
+}
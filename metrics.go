@@ -0,0 +1,282 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// defaultMetricsNamespace 指标名称的默认前缀
+const defaultMetricsNamespace = "filesys"
+
+// MetricsConfig 监控配置，未配置的参数使用默认值
+type MetricsConfig struct {
+	Namespace string    // 指标名称的前缀，默认 filesys
+	Adapter   string    // adapter 标签的值，为空时根据适配器类型生成，例如 OssAdapter 为 oss
+	Buckets   []float64 // 耗时直方图的分桶，单位秒，默认 prometheus.DefBuckets
+}
+
+// storeMetrics 全部存储器共用的指标，通过 adapter 标签区分
+type storeMetrics struct {
+	requests   *prometheus.CounterVec   // 请求次数
+	errors     *prometheus.CounterVec   // 失败次数，按错误分类
+	duration   *prometheus.HistogramVec // 耗时，下载只统计到开始返回内容
+	uploaded   *prometheus.CounterVec   // 上传字节数
+	downloaded *prometheus.CounterVec   // 下载字节数
+}
+
+// NewMetricsMiddleware Prometheus 监控中间件，指标注册到 registerer，为空时注册到 prometheus.DefaultRegisterer。
+// 同一个 registerer 可以多次调用，已注册的指标会被复用
+func NewMetricsMiddleware(registerer prometheus.Registerer, cfg ...*MetricsConfig) (Middleware, error) {
+	config := &MetricsConfig{}
+	if len(cfg) > 0 && cfg[0] != nil {
+		config = cfg[0]
+	}
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = defaultMetricsNamespace
+	}
+	buckets := config.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	var (
+		m   = &storeMetrics{}
+		err error
+	)
+	if m.requests, err = registerCounterVec(registerer, prometheus.CounterOpts{
+		Namespace: namespace, Name: "operations_total", Help: "存储操作的次数",
+	}, "adapter", "op"); err != nil {
+		return nil, err
+	}
+	if m.errors, err = registerCounterVec(registerer, prometheus.CounterOpts{
+		Namespace: namespace, Name: "operation_errors_total", Help: "存储操作失败的次数，按错误分类",
+	}, "adapter", "op", "class"); err != nil {
+		return nil, err
+	}
+	if m.uploaded, err = registerCounterVec(registerer, prometheus.CounterOpts{
+		Namespace: namespace, Name: "uploaded_bytes_total", Help: "上传的字节数",
+	}, "adapter"); err != nil {
+		return nil, err
+	}
+	if m.downloaded, err = registerCounterVec(registerer, prometheus.CounterOpts{
+		Namespace: namespace, Name: "downloaded_bytes_total", Help: "下载的字节数",
+	}, "adapter"); err != nil {
+		return nil, err
+	}
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "operation_duration_seconds", Help: "存储操作的耗时，下载只统计到开始返回内容", Buckets: buckets,
+	}, []string{"adapter", "op"})
+	collector, err := registerCollector(registerer, duration)
+	if err != nil {
+		return nil, err
+	}
+	if m.duration, _ = collector.(*prometheus.HistogramVec); m.duration == nil {
+		return nil, gerror.New("指标operation_duration_seconds已注册为其他类型")
+	}
+
+	return func(next Adapter) Adapter {
+		name := config.Adapter
+		if name == "" {
+			name = adapterName(next)
+		}
+		return &metricsAdapter{AdapterWrapper: AdapterWrapper{Next: next}, metrics: m, adapter: name}
+	}, nil
+}
+
+// registerCounterVec 注册计数器，已经注册过时返回已注册的计数器
+func registerCounterVec(registerer prometheus.Registerer, opts prometheus.CounterOpts, labels ...string) (*prometheus.CounterVec, error) {
+	collector, err := registerCollector(registerer, prometheus.NewCounterVec(opts, labels))
+	if err != nil {
+		return nil, err
+	}
+	counter, ok := collector.(*prometheus.CounterVec)
+	if !ok {
+		return nil, gerror.Newf("指标%s已注册为其他类型", opts.Name)
+	}
+	return counter, nil
+}
+
+// registerCollector 注册指标，已经注册过时返回已注册的指标
+func registerCollector(registerer prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	if err := registerer.Register(collector); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return collector, nil
+}
+
+// adapterName 根据最内层适配器的类型生成名称，例如 *filesys.OssAdapter 为 oss
+func adapterName(adapter Adapter) string {
+	t := reflect.TypeOf(innerAdapter(adapter))
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(strings.TrimSuffix(t.Name(), "Adapter"))
+}
+
+// metricsAdapter 监控中间件
+type metricsAdapter struct {
+	AdapterWrapper
+	metrics *storeMetrics
+	adapter string
+}
+
+// observe 记录一次操作，需要在方法开始时 defer 调用
+func (m *metricsAdapter) observe(op string, start time.Time, err *error) {
+	m.metrics.requests.WithLabelValues(m.adapter, op).Inc()
+	m.metrics.duration.WithLabelValues(m.adapter, op).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		m.metrics.errors.WithLabelValues(m.adapter, op, errorClass(*err)).Inc()
+	}
+}
+
+// countUpload 统计 reader 被读取的字节数
+func (m *metricsAdapter) countUpload(reader io.Reader) io.Reader {
+	return newCountingReader(reader, m.metrics.uploaded.WithLabelValues(m.adapter))
+}
+
+// countDownload 统计 body 被读取的字节数
+func (m *metricsAdapter) countDownload(body io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil {
+		return body, err
+	}
+	counter := m.metrics.downloaded.WithLabelValues(m.adapter)
+	return &countingReadCloser{Reader: newCountingReader(body, counter), Closer: body}, nil
+}
+
+func (m *metricsAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	defer m.observe("Delete", time.Now(), &err)
+	return m.Next.Delete(ctx, objects...)
+}
+
+func (m *metricsAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	defer m.observe("GetSignURL", time.Now(), &err)
+	return m.Next.GetSignURL(ctx, object, expire...)
+}
+
+func (m *metricsAdapter) IsExist(ctx context.Context, object string) (err error) {
+	defer m.observe("IsExist", time.Now(), &err)
+	return m.Next.IsExist(ctx, object)
+}
+
+func (m *metricsAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	defer m.observe("Lists", time.Now(), &err)
+	return m.Next.Lists(ctx, prefix)
+}
+
+func (m *metricsAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	defer m.observe("Upload", time.Now(), &err)
+	return m.Next.Upload(ctx, path, m.countUpload(reader), size, headers...)
+}
+
+func (m *metricsAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	defer m.observe("Download", time.Now(), &err)
+	return m.countDownload(m.Next.Download(ctx, object))
+}
+
+func (m *metricsAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	defer m.observe("GetInfo", time.Now(), &err)
+	return m.Next.GetInfo(ctx, object)
+}
+
+func (m *metricsAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	defer m.observe("ListPage", time.Now(), &err)
+	return m.AdapterWrapper.ListPage(ctx, prefix, token, limit)
+}
+
+func (m *metricsAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	defer m.observe("ListDir", time.Now(), &err)
+	return m.AdapterWrapper.ListDir(ctx, prefix)
+}
+
+func (m *metricsAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	defer m.observe("DownloadRange", time.Now(), &err)
+	return m.countDownload(m.AdapterWrapper.DownloadRange(ctx, object, offset, length))
+}
+
+func (m *metricsAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	defer m.observe("Copy", time.Now(), &err)
+	return m.AdapterWrapper.Copy(ctx, src, dst)
+}
+
+func (m *metricsAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	defer m.observe("Move", time.Now(), &err)
+	return m.AdapterWrapper.Move(ctx, src, dst)
+}
+
+func (m *metricsAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	defer m.observe("InitMultipart", time.Now(), &err)
+	return m.AdapterWrapper.InitMultipart(ctx, object, headers)
+}
+
+func (m *metricsAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	defer m.observe("UploadPart", time.Now(), &err)
+	return m.AdapterWrapper.UploadPart(ctx, object, uploadID, number, m.countUpload(reader), size)
+}
+
+func (m *metricsAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	defer m.observe("CompleteMultipart", time.Now(), &err)
+	return m.AdapterWrapper.CompleteMultipart(ctx, object, uploadID, parts)
+}
+
+func (m *metricsAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	defer m.observe("AbortMultipart", time.Now(), &err)
+	return m.AdapterWrapper.AbortMultipart(ctx, object, uploadID)
+}
+
+func (m *metricsAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	defer m.observe("GetUploadSignURL", time.Now(), &err)
+	return m.AdapterWrapper.GetUploadSignURL(ctx, object, expire, opts)
+}
+
+func (m *metricsAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	defer m.observe("PostPolicy", time.Now(), &err)
+	return m.AdapterWrapper.PostPolicy(ctx, opts)
+}
+
+// countingReader 读取时累加字节数
+type countingReader struct {
+	reader  io.Reader
+	counter prometheus.Counter
+}
+
+// countingReadSeeker 原始 reader 可以 Seek 时保留 Seek，重试上传时需要
+type countingReadSeeker struct {
+	*countingReader
+	io.Seeker
+}
+
+// countingReadCloser 统计下载字节数，关闭时关闭原始的 body
+type countingReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newCountingReader(reader io.Reader, counter prometheus.Counter) io.Reader {
+	r := &countingReader{reader: reader, counter: counter}
+	if seeker, ok := reader.(io.Seeker); ok {
+		return &countingReadSeeker{countingReader: r, Seeker: seeker}
+	}
+	return r
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		r.counter.Add(float64(n))
+	}
+	return
+}
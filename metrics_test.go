@@ -0,0 +1,89 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	mw, err := NewMetricsMiddleware(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一个 registerer 再次调用时复用已注册的指标
+	named, err := NewMetricsMiddleware(registry, &MetricsConfig{Adapter: "backup"})
+	if err != nil {
+		t.Fatalf("second NewMetricsMiddleware: %v", err)
+	}
+	adapter := mw(newMemoryAdapter(t, map[string]string{"a.txt": "hello"}))
+	backup := named(newMemoryAdapter(t, nil))
+
+	if err = adapter.Upload(ctx, "b.txt", strings.NewReader("world!"), 6); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, adapter, "a.txt"); got != "hello" {
+		t.Fatalf("Download = %q", got)
+	}
+	body, err := adapter.(RangeDownloader).DownloadRange(ctx, "b.txt", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, body)
+	body.Close()
+	if _, err = adapter.GetInfo(ctx, "missing.txt"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("GetInfo missing file: %v", err)
+	}
+	if _, err = adapter.GetInfo(ctx, "missing.txt"); err == nil {
+		t.Fatal("GetInfo missing file: want error")
+	}
+	if err = backup.Upload(ctx, "c.txt", strings.NewReader("abc"), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP filesys_operations_total 存储操作的次数
+# TYPE filesys_operations_total counter
+filesys_operations_total{adapter="backup",op="Upload"} 1
+filesys_operations_total{adapter="memory",op="Download"} 1
+filesys_operations_total{adapter="memory",op="DownloadRange"} 1
+filesys_operations_total{adapter="memory",op="GetInfo"} 2
+filesys_operations_total{adapter="memory",op="Upload"} 1
+# HELP filesys_operation_errors_total 存储操作失败的次数，按错误分类
+# TYPE filesys_operation_errors_total counter
+filesys_operation_errors_total{adapter="memory",class="not_exist",op="GetInfo"} 2
+# HELP filesys_uploaded_bytes_total 上传的字节数
+# TYPE filesys_uploaded_bytes_total counter
+filesys_uploaded_bytes_total{adapter="backup"} 3
+filesys_uploaded_bytes_total{adapter="memory"} 6
+# HELP filesys_downloaded_bytes_total 下载的字节数
+# TYPE filesys_downloaded_bytes_total counter
+filesys_downloaded_bytes_total{adapter="memory"} 7
+`
+	if err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"filesys_operations_total", "filesys_operation_errors_total",
+		"filesys_uploaded_bytes_total", "filesys_downloaded_bytes_total"); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(registry, "filesys_operation_duration_seconds"); got != 5 {
+		t.Errorf("operation_duration_seconds has %d series, want 5", got)
+	}
+}
+
+func TestMetricsMiddlewareConflict(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "filesys", Name: "operations_total", Help: "已注册的其他指标"}))
+	if _, err := NewMetricsMiddleware(registry); err == nil {
+		t.Fatal("NewMetricsMiddleware with a conflicting metric: want error")
+	}
+	if _, err := NewMetricsMiddleware(registry, &MetricsConfig{Namespace: "other"}); err != nil {
+		t.Fatalf("NewMetricsMiddleware with another namespace: %v", err)
+	}
+}
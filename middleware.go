@@ -0,0 +1,153 @@
+package filesys
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+)
+
+// Middleware 中间件，包装适配器后返回新的适配器，用于在不修改适配器的情况下添加日志、监控、重试、校验等功能
+type Middleware func(next Adapter) Adapter
+
+// Use 添加中间件，先添加的中间件在最外层，最先执行，需要在使用存储器之前调用
+func (c *Store) Use(mw ...Middleware) {
+	c.middlewares = append(c.middlewares, mw...)
+	c.localAdapter = wrapAdapter(c.adapter, c.middlewares)
+}
+
+// wrapAdapter 按从内到外的顺序包装适配器
+func wrapAdapter(adapter Adapter, middlewares []Middleware) Adapter {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		adapter = middlewares[i](adapter)
+	}
+	return adapter
+}
+
+// AdapterWrapper 中间件的基础实现，全部方法和可选接口都转发给 Next，
+// Next 不支持的可选接口按存储器的方式降级。中间件嵌入后只需要重写关心的方法
+type AdapterWrapper struct {
+	Next Adapter
+}
+
+// Unwrap 返回被包装的适配器
+func (w *AdapterWrapper) Unwrap() Adapter {
+	return w.Next
+}
+
+func (w *AdapterWrapper) Delete(ctx context.Context, objects ...string) (err error) {
+	return w.Next.Delete(ctx, objects...)
+}
+
+func (w *AdapterWrapper) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	return w.Next.GetSignURL(ctx, object, expire...)
+}
+
+func (w *AdapterWrapper) IsExist(ctx context.Context, object string) (err error) {
+	return w.Next.IsExist(ctx, object)
+}
+
+func (w *AdapterWrapper) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	return w.Next.Lists(ctx, prefix)
+}
+
+func (w *AdapterWrapper) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return w.Next.Upload(ctx, path, reader, size, headers...)
+}
+
+func (w *AdapterWrapper) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	return w.Next.Download(ctx, object)
+}
+
+func (w *AdapterWrapper) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	return w.Next.GetInfo(ctx, object)
+}
+
+func (w *AdapterWrapper) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	return listPage(ctx, w.Next, prefix, token, limit)
+}
+
+func (w *AdapterWrapper) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDir(ctx, w.Next, prefix)
+}
+
+func (w *AdapterWrapper) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	return downloadRange(ctx, w.Next, object, offset, length)
+}
+
+func (w *AdapterWrapper) Copy(ctx context.Context, src, dst string) (err error) {
+	return copyObject(ctx, w.Next, src, dst)
+}
+
+func (w *AdapterWrapper) Move(ctx context.Context, src, dst string) (err error) {
+	return moveObject(ctx, w.Next, src, dst)
+}
+
+// InitMultipart 分片上传的方法只有 Next 支持分片上传时才会被存储器调用
+func (w *AdapterWrapper) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	uploader, err := w.multipartUploader()
+	if err != nil {
+		return
+	}
+	return uploader.InitMultipart(ctx, object, headers)
+}
+
+func (w *AdapterWrapper) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	uploader, err := w.multipartUploader()
+	if err != nil {
+		return
+	}
+	return uploader.UploadPart(ctx, object, uploadID, number, reader, size)
+}
+
+func (w *AdapterWrapper) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	uploader, err := w.multipartUploader()
+	if err != nil {
+		return
+	}
+	return uploader.CompleteMultipart(ctx, object, uploadID, parts)
+}
+
+func (w *AdapterWrapper) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	uploader, err := w.multipartUploader()
+	if err != nil {
+		return
+	}
+	return uploader.AbortMultipart(ctx, object, uploadID)
+}
+
+func (w *AdapterWrapper) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	return uploadSignURL(ctx, w.Next, object, expire, opts)
+}
+
+func (w *AdapterWrapper) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	return postPolicy(ctx, w.Next, opts)
+}
+
+func (w *AdapterWrapper) multipartUploader() (MultipartUploader, error) {
+	uploader, ok := asMultipartUploader(w.Next)
+	if !ok {
+		return nil, &storeError{kind: ErrUnsupported, err: gerror.New("适配器不支持分片上传")}
+	}
+	return uploader, nil
+}
+
+// innerAdapter 返回被全部中间件包装的最内层适配器
+func innerAdapter(adapter Adapter) Adapter {
+	for {
+		wrapper, ok := adapter.(interface{ Unwrap() Adapter })
+		if !ok {
+			return adapter
+		}
+		adapter = wrapper.Unwrap()
+	}
+}
+
+// asMultipartUploader 判断适配器是否支持分片上传，
+// 中间件总是实现了分片上传的方法，需要通过 Unwrap 判断被包装的适配器是否支持
+func asMultipartUploader(adapter Adapter) (MultipartUploader, bool) {
+	if _, ok := innerAdapter(adapter).(MultipartUploader); !ok {
+		return nil, false
+	}
+	uploader, ok := adapter.(MultipartUploader)
+	return uploader, ok
+}
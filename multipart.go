@@ -0,0 +1,211 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultPartSize           int64 = 8 << 20  // 默认分片大小 8MB
+	defaultPartConcurrency          = 4        // 默认并发上传的分片数
+	defaultMultipartThreshold int64 = 64 << 20 // 默认文件超过 64MB 时使用分片上传
+
+	maxPartCount     = 10000   // 对象存储通常最多允许 10000 个分片
+	partGrowStart    = 9000    // 大小未知时，超过该分片数后开始增大分片
+	partGrowInterval = 100     // 大小未知时，每上传该数量的分片把分片大小加倍
+	maxPartSize      = 5 << 30 // 单个分片最大 5GB
+)
+
+// MultipartConfig 分片上传配置，只对实现了 MultipartUploader 的适配器生效，
+// 目前为 OSS、COS、OBS、BOS、S3、MinIO、七牛和内存适配器。
+// 又拍云的并行断点续传需要在初始化时提供文件大小，且除最后一个分片外大小必须相同，无法实现 MultipartUploader，
+// 本地、SFTP、FTP、WebDAV 等适配器也不支持，Upload 和 MultipartUpload 都直接上传整个文件
+type MultipartConfig struct {
+	PartSize    int64 `json:"partSize"`    // 分片大小，分片数超过上限时自动增大
+	Concurrency int   `json:"concurrency"` // 并发上传的分片数
+	Threshold   int64 `json:"threshold"`   // 文件大小超过该值或大小未知时，Upload 自动使用分片上传
+}
+
+// withDefault 未配置的参数使用默认值
+func (cfg MultipartConfig) withDefault() MultipartConfig {
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultPartConcurrency
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultMultipartThreshold
+	}
+	return cfg
+}
+
+// fitPartSize 文件大小已知时增大分片大小，使分片数不超过 partGrowStart，分片大小按 MB 取整
+func fitPartSize(partSize, size int64) int64 {
+	if minSize := (size + partGrowStart - 1) / partGrowStart; partSize < minSize {
+		partSize = (minSize + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+	return partSize
+}
+
+// partSizeOf 第 number 个分片的大小。大小未知时分片数可能超过上限，
+// 超过 partGrowStart 后每 partGrowInterval 个分片把分片大小加倍，最大 maxPartSize
+func (cfg MultipartConfig) partSizeOf(number int) int64 {
+	size := cfg.PartSize
+	for n := number - partGrowStart; n > 0 && size < maxPartSize; n -= partGrowInterval {
+		size *= 2
+	}
+	if size > maxPartSize {
+		size = maxPartSize
+	}
+	return size
+}
+
+// useMultipart 判断 Upload 是否需要使用分片上传
+func (c *Store) useMultipart(size int64) bool {
+	if _, ok := asMultipartUploader(c.localAdapter); !ok {
+		return false
+	}
+	return size < 0 || size > c.multipart.withDefault().Threshold
+}
+
+// multipartUpload 分片上传，文件小于一个分片时直接上传，失败时取消本次分片上传
+func (c *Store) multipartUpload(ctx context.Context, path string, reader io.Reader, size int64, headers map[string]string) (err error) {
+	uploader, ok := asMultipartUploader(c.localAdapter)
+	if !ok {
+		return c.localAdapter.Upload(ctx, path, reader, size, headers)
+	}
+	cfg := c.multipart.withDefault()
+	if size >= 0 {
+		cfg.PartSize = fitPartSize(cfg.PartSize, size)
+		reader = io.LimitReader(reader, size)
+	}
+
+	first := make([]byte, cfg.PartSize)
+	n, err := io.ReadFull(reader, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return c.localAdapter.Upload(ctx, path, bytes.NewReader(first[:n]), int64(n), headers)
+	}
+	if err != nil {
+		return
+	}
+	reader = io.MultiReader(bytes.NewReader(first), reader)
+
+	uploadID, err := uploader.InitMultipart(ctx, path, headers)
+	if err != nil {
+		return
+	}
+	parts, err := uploadParts(ctx, uploader, path, uploadID, reader, 1, cfg, nil)
+	if err == nil {
+		err = uploader.CompleteMultipart(ctx, path, uploadID, parts)
+	}
+	if err != nil {
+		// ctx 可能已经取消，使用新的 context 清理已上传的分片
+		uploader.AbortMultipart(context.Background(), path, uploadID)
+	}
+	return
+}
+
+// uploadParts 从 number 开始按顺序读取分片并发上传，分片大小由 partSizeOf 决定，每个分片上传成功后回调 onPart
+func uploadParts(ctx context.Context, uploader MultipartUploader, object, uploadID string, reader io.Reader, number int, cfg MultipartConfig, onPart func(part *Part) error) (parts []*Part, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	setErr := func(e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = e
+			cancel()
+		}
+	}
+
+	// 通过缓冲区数量限制并发数和内存占用
+	buffers := make(chan []byte, cfg.Concurrency)
+	for i := 0; i < cfg.Concurrency; i++ {
+		buffers <- nil
+	}
+	for ctx.Err() == nil {
+		buf := <-buffers
+		// 等待缓冲区时可能已有分片失败
+		if ctx.Err() != nil {
+			break
+		}
+		if partSize := cfg.partSizeOf(number); int64(cap(buf)) < partSize {
+			buf = make([]byte, partSize)
+		} else {
+			buf = buf[:partSize]
+		}
+		n, errRead := io.ReadFull(reader, buf)
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil && errRead != io.ErrUnexpectedEOF {
+			setErr(errRead)
+			break
+		}
+		if number > maxPartCount {
+			setErr(gerror.Newf("分片数超过%d", maxPartCount))
+			break
+		}
+
+		wg.Add(1)
+		go func(number int, buf []byte, n int) {
+			defer func() {
+				buffers <- buf
+				wg.Done()
+			}()
+			part, e := uploader.UploadPart(ctx, object, uploadID, number, bytes.NewReader(buf[:n]), int64(n))
+			if e != nil {
+				setErr(e)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if onPart != nil {
+				if e = onPart(part); e != nil && firstErr == nil {
+					firstErr = e
+					cancel()
+				}
+			}
+			parts = append(parts, part)
+		}(number, buf, n)
+		number++
+
+		if errRead == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return
+}
+
+// mergeHeaders 合并多个header
+func mergeHeaders(headers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, header := range headers {
+		for k, v := range header {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,206 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// faultyMemoryAdapter 可以让指定分片上传失败的内存适配器，并统计分片上传的调用
+type faultyMemoryAdapter struct {
+	*MemoryAdapter
+	mu       sync.Mutex
+	failPart int   // 上传该分片时失败，0 表示不失败
+	err      error // 失败时返回的错误
+	parts    int   // 成功上传的分片数
+	aborted  int   // AbortMultipart 的调用次数
+}
+
+func newFaultyMemoryAdapter(t *testing.T, failPart int) *faultyMemoryAdapter {
+	return &faultyMemoryAdapter{MemoryAdapter: newMemoryAdapter(t, nil), failPart: failPart, err: errors.New("upload part failed")}
+}
+
+func (a *faultyMemoryAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (*Part, error) {
+	a.mu.Lock()
+	fail := number == a.failPart
+	a.mu.Unlock()
+	if fail {
+		return nil, a.err
+	}
+	part, err := a.MemoryAdapter.UploadPart(ctx, object, uploadID, number, reader, size)
+	if err == nil {
+		a.mu.Lock()
+		a.parts++
+		a.mu.Unlock()
+	}
+	return part, err
+}
+
+func (a *faultyMemoryAdapter) AbortMultipart(ctx context.Context, object, uploadID string) error {
+	a.mu.Lock()
+	a.aborted++
+	a.mu.Unlock()
+	return a.MemoryAdapter.AbortMultipart(ctx, object, uploadID)
+}
+
+// pendingUploads 未完成的分片上传数量
+func (a *faultyMemoryAdapter) pendingUploads() int {
+	a.MemoryAdapter.mu.RLock()
+	defer a.MemoryAdapter.mu.RUnlock()
+	return len(a.MemoryAdapter.uploads)
+}
+
+// testData 生成 n 字节的测试数据
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+	return data
+}
+
+// nonSeekReader 隐藏 Seek 和 Len，模拟大小未知的流
+type nonSeekReader struct {
+	io.Reader
+}
+
+func TestFitPartSize(t *testing.T) {
+	tests := []struct {
+		partSize, size, want int64
+	}{
+		{8 << 20, 0, 8 << 20},
+		{8 << 20, 64 << 20, 8 << 20},
+		{8 << 20, 9000 * 8 << 20, 8 << 20},
+		{8 << 20, 9000*8<<20 + 1, 9 << 20},
+		{8 << 20, 1 << 40, 117 << 20},
+	}
+	for _, tt := range tests {
+		got := fitPartSize(tt.partSize, tt.size)
+		if got != tt.want {
+			t.Errorf("fitPartSize(%d, %d) = %d, want %d", tt.partSize, tt.size, got, tt.want)
+		}
+		if count := (tt.size + got - 1) / got; count > partGrowStart {
+			t.Errorf("fitPartSize(%d, %d): %d parts", tt.partSize, tt.size, count)
+		}
+	}
+}
+
+func TestPartSizeOf(t *testing.T) {
+	cfg := MultipartConfig{PartSize: 8 << 20}
+	tests := []struct {
+		number int
+		want   int64
+	}{
+		{1, 8 << 20},
+		{partGrowStart, 8 << 20},
+		{partGrowStart + 1, 16 << 20},
+		{partGrowStart + partGrowInterval, 16 << 20},
+		{partGrowStart + partGrowInterval + 1, 32 << 20},
+		{maxPartCount, maxPartSize},
+	}
+	for _, tt := range tests {
+		if got := cfg.partSizeOf(tt.number); got != tt.want {
+			t.Errorf("partSizeOf(%d) = %d, want %d", tt.number, got, tt.want)
+		}
+	}
+
+	// 大小未知时 10000 个分片可以上传的总大小
+	var total int64
+	for number := 1; number <= maxPartCount; number++ {
+		total += cfg.partSizeOf(number)
+	}
+	if total < 1<<40 {
+		t.Errorf("total size of %d parts = %d, want at least 1TB", maxPartCount, total)
+	}
+}
+
+func TestStoreMultipartUpload(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		size  int
+		known bool
+		parts int // 分片数，0 表示直接上传
+	}{
+		{"empty", 0, true, 0},
+		{"smaller than a part", 3, true, 0},
+		{"under threshold", 8, true, 0},
+		{"over threshold", 9, true, 3},
+		{"exact parts", 12, true, 3},
+		{"unknown size", 10, false, 3},
+		{"unknown size smaller than a part", 3, false, 0},
+		{"unknown size of one part", 4, false, 1},
+		{"unknown size empty", 0, false, 0},
+	}
+	for _, tt := range tests {
+		for _, concurrency := range []int{1, 3} {
+			adapter := newFaultyMemoryAdapter(t, 0)
+			store := NewWithAdapter(adapter)
+			store.SetMultipartConfig(MultipartConfig{PartSize: 4, Threshold: 8, Concurrency: concurrency})
+			data := testData(tt.size)
+			var (
+				reader io.Reader = bytes.NewReader(data)
+				size             = int64(tt.size)
+			)
+			if !tt.known {
+				reader, size = &nonSeekReader{reader}, -1
+			}
+			if err := store.Upload(ctx, "file.bin", reader, size, map[string]string{"Content-Type": "text/plain"}); err != nil {
+				t.Fatalf("%s: Upload: %v", tt.name, err)
+			}
+			if got := readObject(t, adapter, "file.bin"); got != string(data) {
+				t.Errorf("%s: content = %q, want %q", tt.name, got, data)
+			}
+			if info, err := adapter.GetInfo(ctx, "file.bin"); err != nil || info.Header["Content-Type"] != "text/plain" {
+				t.Errorf("%s: GetInfo = %v, %v", tt.name, info, err)
+			}
+			if adapter.parts != tt.parts || adapter.pendingUploads() != 0 {
+				t.Errorf("%s: %d parts, %d pending uploads, want %d parts", tt.name, adapter.parts, adapter.pendingUploads(), tt.parts)
+			}
+		}
+	}
+}
+
+func TestStoreMultipartUploadAbort(t *testing.T) {
+	ctx := context.Background()
+	for _, failPart := range []int{1, 2, 3} {
+		adapter := newFaultyMemoryAdapter(t, failPart)
+		store := NewWithAdapter(adapter)
+		store.SetMultipartConfig(MultipartConfig{PartSize: 4, Concurrency: 2})
+		err := store.MultipartUpload(ctx, "file.bin", bytes.NewReader(testData(10)), 10)
+		if !errors.Is(err, adapter.err) {
+			t.Fatalf("fail part %d: MultipartUpload = %v, want %v", failPart, err, adapter.err)
+		}
+		if adapter.aborted != 1 || adapter.pendingUploads() != 0 {
+			t.Errorf("fail part %d: aborted %d times, %d pending uploads", failPart, adapter.aborted, adapter.pendingUploads())
+		}
+		if err = adapter.IsExist(ctx, "file.bin"); !errors.Is(err, ErrNotExist) {
+			t.Errorf("fail part %d: file exists after a failed upload", failPart)
+		}
+	}
+
+	// 读取失败时同样取消
+	adapter := newFaultyMemoryAdapter(t, 0)
+	store := NewWithAdapter(adapter)
+	store.SetMultipartConfig(MultipartConfig{PartSize: 4})
+	readErr := errors.New("read failed")
+	reader := io.MultiReader(bytes.NewReader(testData(6)), &errorReader{err: readErr})
+	if err := store.MultipartUpload(ctx, "file.bin", reader, -1); !errors.Is(err, readErr) {
+		t.Fatalf("MultipartUpload with read error = %v, want %v", err, readErr)
+	}
+	if adapter.aborted != 1 || adapter.pendingUploads() != 0 {
+		t.Errorf("read error: aborted %d times, %d pending uploads", adapter.aborted, adapter.pendingUploads())
+	}
+}
+
+// errorReader 读取时返回错误
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
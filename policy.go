@@ -0,0 +1,89 @@
+package filesys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"strings"
+	"time"
+)
+
+// postPolicy 生成表单上传策略
+func postPolicy(ctx context.Context, adapter Adapter, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	signer, ok := adapter.(PostPolicySigner)
+	if !ok {
+		return nil, &storeError{kind: ErrUnsupported, err: gerror.New("适配器不支持表单上传")}
+	}
+	if opts == nil {
+		opts = &PostPolicyOptions{}
+	}
+	return signer.PostPolicy(ctx, opts)
+}
+
+// postPolicyKey 表单中的文件名字段，只限制前缀时使用上传的文件名
+func postPolicyKey(opts *PostPolicyOptions) string {
+	if opts.Key != "" {
+		return objectRel(opts.Key)
+	}
+	return objectRel(opts.KeyPrefix) + "${filename}"
+}
+
+// policyConditions 生成 S3 风格的策略条件，extra 为各平台要求的额外条件
+func policyConditions(opts *PostPolicyOptions, extra ...interface{}) []interface{} {
+	conditions := append([]interface{}{}, extra...)
+	if opts.Key != "" {
+		conditions = append(conditions, []interface{}{"eq", "$key", objectRel(opts.Key)})
+	} else {
+		conditions = append(conditions, []interface{}{"starts-with", "$key", objectRel(opts.KeyPrefix)})
+	}
+	if opts.MaxSize > 0 {
+		conditions = append(conditions, []interface{}{"content-length-range", opts.MinSize, opts.MaxSize})
+	}
+	if opts.ContentType != "" {
+		conditions = append(conditions, []interface{}{"eq", "$Content-Type", opts.ContentType})
+	}
+	return conditions
+}
+
+// policyFields 表单中除签名外的公共字段
+func policyFields(opts *PostPolicyOptions) map[string]string {
+	fields := map[string]string{
+		"key": postPolicyKey(opts),
+	}
+	if opts.ContentType != "" {
+		fields["Content-Type"] = opts.ContentType
+	}
+	return fields
+}
+
+// encodePolicy 把策略序列化为JSON，返回原始JSON和base64编码后的策略
+func encodePolicy(expire time.Time, conditions []interface{}) (origin []byte, policy string, err error) {
+	origin, err = json.Marshal(map[string]interface{}{
+		"expiration": expire.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return
+	}
+	return origin, base64.StdEncoding.EncodeToString(origin), nil
+}
+
+// hmacSha1 HMAC-SHA1 签名
+func hmacSha1(key string, data string) []byte {
+	h := hmac.New(sha1.New, []byte(key))
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// bucketEndpoint 存储桶的访问地址，endpoint 未指定协议时使用 https
+func bucketEndpoint(bucket, endpoint string) string {
+	scheme := "https://"
+	if strings.HasPrefix(endpoint, "http://") {
+		scheme = "http://"
+	}
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+	return scheme + bucket + "." + strings.TrimRight(endpoint, "/")
+}
@@ -0,0 +1,52 @@
+package filesys
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"io"
+)
+
+// limitReadCloser 限制读取长度，关闭时关闭原始的 body
+type limitReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// downloadRange 分段下载，适配器不支持时下载整个文件，跳过 offset 之前的内容
+func downloadRange(ctx context.Context, adapter Adapter, object string, offset, length int64) (body io.ReadCloser, err error) {
+	if offset < 0 {
+		return nil, gerror.Newf("下载偏移量[%d]不合法", offset)
+	}
+	if downloader, ok := adapter.(RangeDownloader); ok {
+		return downloader.DownloadRange(ctx, object, offset, length)
+	}
+
+	if body, err = adapter.Download(ctx, object); err != nil {
+		return
+	}
+	if offset > 0 {
+		// 偏移量超出文件大小时返回空内容
+		if _, err = io.CopyN(io.Discard, body, offset); err != nil && err != io.EOF {
+			body.Close()
+			return nil, err
+		}
+	}
+	return limitBody(body, length), nil
+}
+
+// limitBody 限制 body 的读取长度，length 小于等于0时不限制
+func limitBody(body io.ReadCloser, length int64) io.ReadCloser {
+	if length <= 0 {
+		return body
+	}
+	return &limitReadCloser{Reader: io.LimitReader(body, length), Closer: body}
+}
+
+// httpRange 生成HTTP Range请求头，length 小于等于0时读取到文件末尾
+func httpRange(offset, length int64) string {
+	if length <= 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
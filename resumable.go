@@ -0,0 +1,144 @@
+package filesys
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/util/guid"
+	"io"
+	"sort"
+	"time"
+)
+
+// checkpointStore 断点续传进度的存储，创建存储器时默认使用系统临时目录
+func (c *Store) checkpointStore() CheckpointStore {
+	return c.checkpoints
+}
+
+// multipartUploader 断点续传依赖分片上传
+func (c *Store) multipartUploader() (MultipartUploader, error) {
+	uploader, ok := asMultipartUploader(c.localAdapter)
+	if !ok {
+		return nil, &storeError{kind: ErrUnsupported, err: gerror.New("适配器不支持分片上传，无法断点续传")}
+	}
+	return uploader, nil
+}
+
+// resumableUpload 开始一次可断点续传的分片上传，失败时保留已上传的分片和进度
+func (c *Store) resumableUpload(ctx context.Context, path string, reader io.Reader, size int64, headers map[string]string) (checkpointID string, err error) {
+	uploader, err := c.multipartUploader()
+	if err != nil {
+		return
+	}
+	uploadID, err := uploader.InitMultipart(ctx, path, headers)
+	if err != nil {
+		return
+	}
+	partSize := c.multipart.withDefault().PartSize
+	if size >= 0 {
+		partSize = fitPartSize(partSize, size)
+	}
+	now := time.Now()
+	checkpoint := &Checkpoint{
+		ID:        guid.S(),
+		Object:    path,
+		UploadID:  uploadID,
+		Size:      size,
+		PartSize:  partSize,
+		Headers:   headers,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = c.checkpointStore().Save(ctx, checkpoint); err != nil {
+		uploader.AbortMultipart(context.Background(), path, uploadID)
+		return
+	}
+	if size >= 0 {
+		reader = io.LimitReader(reader, size)
+	}
+	return checkpoint.ID, c.uploadCheckpoint(ctx, uploader, checkpoint, reader)
+}
+
+// resumeUpload 从最后一个连续上传成功的分片继续上传，reader 需要从文件开头读取
+func (c *Store) resumeUpload(ctx context.Context, checkpointID string, reader io.Reader) (err error) {
+	uploader, err := c.multipartUploader()
+	if err != nil {
+		return
+	}
+	checkpoint, err := c.checkpointStore().Load(ctx, checkpointID)
+	if err != nil {
+		return
+	}
+
+	// 分片是并发上传的，只保留从第一个分片开始连续的部分，之后的分片重新上传
+	sort.Slice(checkpoint.Parts, func(i, j int) bool {
+		return checkpoint.Parts[i].Number < checkpoint.Parts[j].Number
+	})
+	var offset int64
+	for i, part := range checkpoint.Parts {
+		if part.Number != i+1 {
+			checkpoint.Parts = checkpoint.Parts[:i]
+			break
+		}
+		offset += part.Size
+	}
+
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err = seeker.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, reader, offset)
+	}
+	if err != nil {
+		return gerror.Wrapf(err, "跳过已上传的%d字节失败", offset)
+	}
+	if checkpoint.Size >= 0 {
+		reader = io.LimitReader(reader, checkpoint.Size-offset)
+	}
+	return c.uploadCheckpoint(ctx, uploader, checkpoint, reader)
+}
+
+// abortUpload 取消断点续传，清理已上传的分片和进度
+func (c *Store) abortUpload(ctx context.Context, checkpointID string) (err error) {
+	uploader, err := c.multipartUploader()
+	if err != nil {
+		return
+	}
+	checkpoint, err := c.checkpointStore().Load(ctx, checkpointID)
+	if err != nil {
+		return
+	}
+	if err = uploader.AbortMultipart(ctx, checkpoint.Object, checkpoint.UploadID); err != nil {
+		return
+	}
+	return c.checkpointStore().Delete(ctx, checkpointID)
+}
+
+// uploadCheckpoint 上传剩余分片，每个分片成功后保存进度，全部完成后合并分片并删除进度
+func (c *Store) uploadCheckpoint(ctx context.Context, uploader MultipartUploader, checkpoint *Checkpoint, reader io.Reader) (err error) {
+	store := c.checkpointStore()
+	cfg := c.multipart.withDefault()
+	cfg.PartSize = checkpoint.PartSize
+
+	_, err = uploadParts(ctx, uploader, checkpoint.Object, checkpoint.UploadID, reader, len(checkpoint.Parts)+1, cfg, func(part *Part) error {
+		checkpoint.Parts = append(checkpoint.Parts, part)
+		checkpoint.UpdatedAt = time.Now()
+		return store.Save(ctx, checkpoint)
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Slice(checkpoint.Parts, func(i, j int) bool {
+		return checkpoint.Parts[i].Number < checkpoint.Parts[j].Number
+	})
+	if len(checkpoint.Parts) == 0 {
+		// 空文件没有分片可以合并，直接上传
+		uploader.AbortMultipart(ctx, checkpoint.Object, checkpoint.UploadID)
+		err = c.localAdapter.Upload(ctx, checkpoint.Object, reader, 0, checkpoint.Headers)
+	} else {
+		err = uploader.CompleteMultipart(ctx, checkpoint.Object, checkpoint.UploadID, checkpoint.Parts)
+	}
+	if err != nil {
+		return
+	}
+	return store.Delete(ctx, checkpoint.ID)
+}
@@ -0,0 +1,108 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStoreResumableUpload(t *testing.T) {
+	ctx := context.Background()
+	data := testData(14)
+	tests := []struct {
+		name        string
+		size        int64
+		concurrency int
+		seekable    bool
+	}{
+		{"known size", 14, 1, true},
+		{"unknown size", -1, 1, false},
+		{"concurrent", 14, 3, true},
+		{"concurrent non-seekable", 14, 3, false},
+	}
+	for _, tt := range tests {
+		adapter := newFaultyMemoryAdapter(t, 3)
+		store := NewWithAdapter(adapter)
+		store.SetMultipartConfig(MultipartConfig{PartSize: 4, Concurrency: tt.concurrency})
+		checkpoints := NewFileCheckpointStore(t.TempDir())
+		store.SetCheckpointStore(checkpoints)
+
+		checkpointID, err := store.ResumableUpload(ctx, "file.bin", bytes.NewReader(data), tt.size, map[string]string{"Content-Type": "text/plain"})
+		if !errors.Is(err, adapter.err) || checkpointID == "" {
+			t.Fatalf("%s: ResumableUpload = %q, %v, want %v", tt.name, checkpointID, err, adapter.err)
+		}
+		checkpoint, err := checkpoints.Load(ctx, checkpointID)
+		if err != nil {
+			t.Fatalf("%s: Load checkpoint: %v", tt.name, err)
+		}
+		if checkpoint.Size != tt.size || checkpoint.PartSize != 4 || adapter.aborted != 0 {
+			t.Fatalf("%s: checkpoint = %+v, aborted %d times", tt.name, checkpoint, adapter.aborted)
+		}
+		if tt.concurrency == 1 && len(checkpoint.Parts) != 2 {
+			t.Fatalf("%s: %d parts saved, want 2", tt.name, len(checkpoint.Parts))
+		}
+
+		adapter.mu.Lock()
+		adapter.failPart = 0
+		adapter.mu.Unlock()
+		var reader io.Reader = bytes.NewReader(data)
+		if !tt.seekable {
+			reader = &nonSeekReader{reader}
+		}
+		if err = store.ResumeUpload(ctx, checkpointID, reader); err != nil {
+			t.Fatalf("%s: ResumeUpload: %v", tt.name, err)
+		}
+		if got := readObject(t, adapter, "file.bin"); got != string(data) {
+			t.Errorf("%s: content = %q, want %q", tt.name, got, data)
+		}
+		if info, err := adapter.GetInfo(ctx, "file.bin"); err != nil || info.Header["Content-Type"] != "text/plain" {
+			t.Errorf("%s: GetInfo = %v, %v", tt.name, info, err)
+		}
+		if _, err = checkpoints.Load(ctx, checkpointID); !errors.Is(err, ErrNotExist) {
+			t.Errorf("%s: checkpoint not deleted after completion: %v", tt.name, err)
+		}
+		if adapter.pendingUploads() != 0 {
+			t.Errorf("%s: %d pending uploads", tt.name, adapter.pendingUploads())
+		}
+	}
+}
+
+func TestStoreAbortUpload(t *testing.T) {
+	ctx := context.Background()
+	adapter := newFaultyMemoryAdapter(t, 2)
+	store := NewWithAdapter(adapter)
+	store.SetMultipartConfig(MultipartConfig{PartSize: 4, Concurrency: 1})
+	checkpoints := NewFileCheckpointStore(t.TempDir())
+	store.SetCheckpointStore(checkpoints)
+
+	checkpointID, err := store.ResumableUpload(ctx, "file.bin", bytes.NewReader(testData(10)), 10)
+	if err == nil {
+		t.Fatal("ResumableUpload: want error")
+	}
+	if adapter.pendingUploads() != 1 {
+		t.Fatalf("%d pending uploads, want 1", adapter.pendingUploads())
+	}
+	if err = store.AbortUpload(ctx, checkpointID); err != nil {
+		t.Fatal(err)
+	}
+	if adapter.pendingUploads() != 0 {
+		t.Errorf("%d pending uploads after AbortUpload", adapter.pendingUploads())
+	}
+	if _, err = checkpoints.Load(ctx, checkpointID); !errors.Is(err, ErrNotExist) {
+		t.Errorf("checkpoint not deleted after AbortUpload: %v", err)
+	}
+	if err = store.ResumeUpload(ctx, checkpointID, bytes.NewReader(testData(10))); !errors.Is(err, ErrNotExist) {
+		t.Errorf("ResumeUpload after AbortUpload: got %v, want ErrNotExist", err)
+	}
+}
+
+func TestStoreResumableUploadUnsupported(t *testing.T) {
+	store := NewWithAdapter(&plainAdapter{newMemoryAdapter(t, nil)})
+	store.SetCheckpointStore(NewFileCheckpointStore(t.TempDir()))
+	_, err := store.ResumableUpload(context.Background(), "file.bin", bytes.NewReader(testData(10)), 10)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("ResumableUpload = %v, want ErrUnsupported", err)
+	}
+}
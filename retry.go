@@ -0,0 +1,248 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryAttempts = 3                      // 默认最多尝试次数
+	defaultRetryDelay    = 200 * time.Millisecond // 默认第一次重试前的等待时间
+	defaultRetryMaxDelay = 5 * time.Second        // 默认最长等待时间
+	defaultRetryJitter   = 0.5                    // 默认等待时间的随机抖动比例
+)
+
+// RetryConfig 重试配置，未配置的参数使用默认值
+type RetryConfig struct {
+	MaxAttempts int                                                          // 最多尝试次数，包含第一次，默认3
+	BaseDelay   time.Duration                                                // 第一次重试前的等待时间，之后每次翻倍，默认200ms
+	MaxDelay    time.Duration                                                // 最长等待时间，默认5s
+	Jitter      float64                                                      // 等待时间随机减少的最大比例，0到1之间，默认0.5，小于0时不抖动
+	Retryable   func(err error) bool                                         // 判断错误是否可以重试，默认使用 IsRetryable
+	OnRetry     func(ctx context.Context, op string, attempt int, err error) // 每次重试前回调，可用于记录日志
+}
+
+func (cfg RetryConfig) withDefault() RetryConfig {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultRetryAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultRetryDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultRetryMaxDelay
+	}
+	if cfg.Jitter == 0 {
+		cfg.Jitter = defaultRetryJitter
+	} else if cfg.Jitter < 0 {
+		cfg.Jitter = 0
+	} else if cfg.Jitter > 1 {
+		cfg.Jitter = 1
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = IsRetryable
+	}
+	return cfg
+}
+
+// backoff 第 attempt 次失败后的等待时间，指数增长并随机抖动，避免大量请求同时重试
+func (cfg RetryConfig) backoff(attempt int) time.Duration {
+	delay := cfg.BaseDelay
+	for i := 1; i < attempt && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	if jitter := int64(float64(delay) * cfg.Jitter); jitter > 0 {
+		retryRandMu.Lock()
+		delay -= time.Duration(retryRand.Int63n(jitter + 1))
+		retryRandMu.Unlock()
+	}
+	return delay
+}
+
+var (
+	retryRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	retryRandMu sync.Mutex
+)
+
+// IsRetryable 判断错误是否为临时错误，包括各适配器归类的服务端临时错误（超时、限流、5xx、FTP 4xx）和网络错误，
+// ctx 取消、文件不存在、没有权限等错误不会重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// 批量操作的错误中只要有一个可以重试，重新执行整个操作
+	var joined *joinedError
+	if errors.As(err, &joined) {
+		for _, e := range joined.errs {
+			if IsRetryable(e) {
+				return true
+			}
+		}
+		return false
+	}
+	var storeErr *storeError
+	if errors.As(err, &storeErr) && storeErr.temporary {
+		return true
+	}
+	for _, kind := range []error{ErrNotExist, ErrPermission, ErrAlreadyExists, ErrInvalidPath, ErrUnsupported} {
+		if errors.Is(err, kind) {
+			return false
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// 部分驱动只保留了错误信息
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe")
+}
+
+// NewRetryMiddleware 重试中间件，遇到临时错误时按指数退避重试。
+// 只重试幂等的操作：GetInfo、IsExist、Download、DownloadRange、Lists、ListPage、ListDir、Delete，
+// 以及 reader 可以 Seek 的 Upload 和 UploadPart，下载流读取过程中的错误不会重试
+func NewRetryMiddleware(cfg RetryConfig) Middleware {
+	cfg = cfg.withDefault()
+	return func(next Adapter) Adapter {
+		return &retryAdapter{AdapterWrapper: AdapterWrapper{Next: next}, config: cfg}
+	}
+}
+
+// retryAdapter 重试中间件
+type retryAdapter struct {
+	AdapterWrapper
+	config RetryConfig
+}
+
+// do 执行 fn，失败时等待后重试，ctx 取消或剩余时间不足以等待时返回最后一次的错误
+func (r *retryAdapter) do(ctx context.Context, op string, fn func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= r.config.MaxAttempts || !r.config.Retryable(err) {
+			return
+		}
+		delay := r.config.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return
+		}
+		if r.config.OnRetry != nil {
+			r.config.OnRetry(ctx, op, attempt, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// doSeekable reader 可以 Seek 时，每次重试前回到开始的位置，否则只执行一次
+func (r *retryAdapter) doSeekable(ctx context.Context, op string, reader io.Reader, fn func() error) (err error) {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return fn()
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fn()
+	}
+	attempted := false
+	return r.do(ctx, op, func() error {
+		if attempted {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		attempted = true
+		return fn()
+	})
+}
+
+func (r *retryAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	return r.do(ctx, "Delete", func() error {
+		return r.Next.Delete(ctx, objects...)
+	})
+}
+
+func (r *retryAdapter) IsExist(ctx context.Context, object string) (err error) {
+	return r.do(ctx, "IsExist", func() error {
+		return r.Next.IsExist(ctx, object)
+	})
+}
+
+func (r *retryAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	err = r.do(ctx, "Lists", func() (err error) {
+		files, err = r.Next.Lists(ctx, prefix)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return r.doSeekable(ctx, "Upload", reader, func() error {
+		return r.Next.Upload(ctx, path, reader, size, headers...)
+	})
+}
+
+func (r *retryAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	err = r.do(ctx, "Download", func() (err error) {
+		body, err = r.Next.Download(ctx, object)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	err = r.do(ctx, "GetInfo", func() (err error) {
+		info, err = r.Next.GetInfo(ctx, object)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	err = r.do(ctx, "ListPage", func() (err error) {
+		result, err = listPage(ctx, r.Next, prefix, token, limit)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	err = r.do(ctx, "ListDir", func() (err error) {
+		files, err = listDir(ctx, r.Next, prefix)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	err = r.do(ctx, "DownloadRange", func() (err error) {
+		body, err = downloadRange(ctx, r.Next, object, offset, length)
+		return
+	})
+	return
+}
+
+func (r *retryAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	err = r.doSeekable(ctx, "UploadPart", reader, func() (err error) {
+		part, err = r.AdapterWrapper.UploadPart(ctx, object, uploadID, number, reader, size)
+		return
+	})
+	return
+}
@@ -0,0 +1,187 @@
+package filesys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError 超时的网络错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("get: %w", context.DeadlineExceeded), false},
+		{"temporary", temporaryError(errors.New("slow down")), true},
+		{"status 503", statusError(http.StatusServiceUnavailable, errors.New("unavailable")), true},
+		{"status 429", statusError(http.StatusTooManyRequests, errors.New("throttled")), true},
+		{"status 404", statusError(http.StatusNotFound, errors.New("not found")), false},
+		{"not exist", notExistError("a.txt"), false},
+		{"permission", wrapError(ErrPermission, errors.New("denied")), false},
+		{"network timeout", fmt.Errorf("dial: %w", timeoutError{}), true},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"reset message", errors.New("read tcp: connection reset by peer"), true},
+		{"other", errors.New("bad request"), false},
+		{"joined retryable", joinErrors(notExistError("a"), temporaryError(errors.New("b"))), true},
+		{"joined permanent", joinErrors(notExistError("a"), wrapError(ErrPermission, errors.New("b"))), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: -1}.withDefault()
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := cfg.backoff(attempt + 1); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %v, want %v", attempt+1, got, want*time.Millisecond)
+		}
+	}
+
+	cfg.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := cfg.backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("backoff(2) with jitter = %v, want between 100ms and 200ms", got)
+		}
+	}
+}
+
+// flakyAdapter 前 failures 次调用返回 err 的内存适配器
+type flakyAdapter struct {
+	*MemoryAdapter
+	mu       sync.Mutex
+	failures int
+	err      error
+	calls    int
+}
+
+// call 记录一次调用，返回本次调用是否需要失败
+func (a *flakyAdapter) call() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls++
+	if a.calls <= a.failures {
+		return a.err
+	}
+	return nil
+}
+
+func (a *flakyAdapter) GetInfo(ctx context.Context, object string) (*File, error) {
+	if err := a.call(); err != nil {
+		return nil, err
+	}
+	return a.MemoryAdapter.GetInfo(ctx, object)
+}
+
+// Upload 失败时已经读取了部分内容，重试时需要回到开始的位置
+func (a *flakyAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) error {
+	if err := a.call(); err != nil {
+		io.CopyN(io.Discard, reader, 2)
+		return err
+	}
+	return a.MemoryAdapter.Upload(ctx, path, reader, size, headers...)
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	ctx := context.Background()
+	temporary := temporaryError(errors.New("unavailable"))
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		calls    int
+		wantErr  bool
+	}{
+		{"success", 0, temporary, 1, false},
+		{"recovered", 2, temporary, 3, false},
+		{"exhausted", 5, temporary, 3, true},
+		{"permanent", 5, wrapError(ErrPermission, errors.New("denied")), 1, true},
+		{"canceled", 5, context.Canceled, 1, true},
+	}
+	for _, tt := range tests {
+		adapter := &flakyAdapter{MemoryAdapter: newMemoryAdapter(t, map[string]string{"a.txt": "abc"}), failures: tt.failures, err: tt.err}
+		var retries []int
+		retry := NewRetryMiddleware(RetryConfig{BaseDelay: time.Millisecond, OnRetry: func(ctx context.Context, op string, attempt int, err error) {
+			if op != "GetInfo" || !errors.Is(err, tt.err) {
+				t.Errorf("%s: OnRetry(%s, %d, %v)", tt.name, op, attempt, err)
+			}
+			retries = append(retries, attempt)
+		}})(adapter)
+		info, err := retry.GetInfo(ctx, "a.txt")
+		if (err != nil) != tt.wantErr || (err == nil && info.Size != 3) {
+			t.Errorf("%s: GetInfo = %v, %v", tt.name, info, err)
+		}
+		if adapter.calls != tt.calls || len(retries) != tt.calls-1 {
+			t.Errorf("%s: %d calls, retries %v, want %d calls", tt.name, adapter.calls, retries, tt.calls)
+		}
+	}
+}
+
+func TestRetryMiddlewareUpload(t *testing.T) {
+	ctx := context.Background()
+	temporary := temporaryError(errors.New("unavailable"))
+
+	// reader 可以 Seek 时从开始的位置重新上传
+	adapter := &flakyAdapter{MemoryAdapter: newMemoryAdapter(t, nil), failures: 2, err: temporary}
+	retry := NewRetryMiddleware(RetryConfig{BaseDelay: time.Millisecond})(adapter)
+	reader := bytes.NewReader([]byte("skip:content"))
+	reader.Seek(5, io.SeekStart)
+	if err := retry.Upload(ctx, "a.txt", reader, 7); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, adapter, "a.txt"); adapter.calls != 3 || got != "content" {
+		t.Errorf("seekable Upload: %d calls, content %q", adapter.calls, got)
+	}
+
+	// reader 不能 Seek 时只上传一次
+	adapter = &flakyAdapter{MemoryAdapter: newMemoryAdapter(t, nil), failures: 2, err: temporary}
+	retry = NewRetryMiddleware(RetryConfig{BaseDelay: time.Millisecond})(adapter)
+	if err := retry.Upload(ctx, "a.txt", &nonSeekReader{strings.NewReader("content")}, 7); !errors.Is(err, temporary) || adapter.calls != 1 {
+		t.Errorf("non-seekable Upload = %v after %d calls", err, adapter.calls)
+	}
+}
+
+func TestRetryMiddlewareDeadline(t *testing.T) {
+	adapter := &flakyAdapter{MemoryAdapter: newMemoryAdapter(t, nil), failures: 5, err: temporaryError(errors.New("unavailable"))}
+	retry := NewRetryMiddleware(RetryConfig{BaseDelay: time.Hour, MaxDelay: time.Hour, Jitter: -1})(adapter)
+
+	// 剩余时间不足以等待时直接返回最后一次的错误
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	if _, err := retry.GetInfo(ctx, "a.txt"); !errors.Is(err, adapter.err) || adapter.calls != 1 {
+		t.Errorf("GetInfo = %v after %d calls", err, adapter.calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetInfo waited %v", elapsed)
+	}
+
+	// 等待时 ctx 取消
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	adapter.calls = 0
+	if _, err := retry.GetInfo(ctx, "a.txt"); !errors.Is(err, adapter.err) || adapter.calls != 1 {
+		t.Errorf("GetInfo with canceled ctx = %v after %d calls", err, adapter.calls)
+	}
+}
@@ -0,0 +1,60 @@
+package filesys
+
+import (
+	"context"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"net/http"
+	"time"
+)
+
+// defaultUploadExpire 上传签名默认的有效期，单位秒
+const defaultUploadExpire int64 = 1800
+
+// uploadSignURL 生成客户端直传签名
+func uploadSignURL(ctx context.Context, adapter Adapter, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	signer, ok := adapter.(UploadSigner)
+	if !ok {
+		return nil, &storeError{kind: ErrUnsupported, err: gerror.New("适配器不支持客户端直传")}
+	}
+	if opts == nil {
+		opts = &UploadSignOptions{}
+	}
+	return signer.GetUploadSignURL(ctx, object, expire, opts)
+}
+
+// uploadExpire 上传签名的有效期，未指定时依次使用配置的有效期和默认有效期
+func uploadExpire(expire, cfgExpire int64) int64 {
+	if expire > 0 {
+		return expire
+	}
+	if cfgExpire > 0 {
+		return cfgExpire
+	}
+	return defaultUploadExpire
+}
+
+// newUploadSign 生成 PUT 上传签名的基本信息
+func newUploadSign(link string, expire int64, opts *UploadSignOptions) *UploadSign {
+	sign := &UploadSign{
+		URL:     link,
+		Method:  http.MethodPut,
+		Headers: map[string]string{},
+		Fields:  map[string]string{},
+		Expire:  time.Now().Add(time.Duration(expire) * time.Second),
+	}
+	if opts.ContentType != "" {
+		sign.Headers["Content-Type"] = opts.ContentType
+	}
+	return sign
+}
+
+// postUploadSign 把表单上传策略转换为上传签名
+func postUploadSign(policy *PostPolicyResult) *UploadSign {
+	return &UploadSign{
+		URL:     policy.URL,
+		Method:  http.MethodPost,
+		Headers: map[string]string{},
+		Fields:  policy.Fields,
+		Expire:  policy.Expire,
+	}
+}
@@ -13,7 +13,11 @@ var (
 )
 
 type Store struct {
-	localAdapter
+	localAdapter                 // 经过中间件包装的适配器
+	adapter      Adapter         // 未经过中间件包装的适配器
+	middlewares  []Middleware    // 中间件，先添加的在最外层
+	multipart    MultipartConfig // 分片上传配置
+	checkpoints  CheckpointStore // 断点续传进度存储
 }
 
 type localAdapter = Adapter
@@ -21,15 +25,33 @@ type localAdapter = Adapter
 func NewWithAdapter(adapter Adapter) *Store {
 	return &Store{
 		localAdapter: adapter,
+		adapter:      adapter,
+		checkpoints:  NewFileCheckpointStore(""),
 	}
 }
 
+// SetAdapter 更换适配器，已添加的中间件会重新包装新的适配器
 func (c *Store) SetAdapter(adapter Adapter) {
-	c.localAdapter = adapter
+	c.adapter = adapter
+	c.localAdapter = wrapAdapter(adapter, c.middlewares)
 }
 
+// GetAdapter 返回未经过中间件包装的适配器
 func (c *Store) GetAdapter() Adapter {
-	return c.localAdapter
+	return c.adapter
+}
+
+// SetMultipartConfig 设置分片上传配置，未配置的参数使用默认值
+func (c *Store) SetMultipartConfig(cfg MultipartConfig) {
+	c.multipart = cfg
+}
+
+// SetCheckpointStore 设置断点续传进度的存储，默认保存在系统临时目录，需要在使用存储器之前调用
+func (c *Store) SetCheckpointStore(store CheckpointStore) {
+	if store == nil {
+		store = NewFileCheckpointStore("")
+	}
+	c.checkpoints = store
 }
 
 // Delete 删除文件
@@ -44,10 +66,17 @@ func (c *Store) Deletes(ctx context.Context, objects []string) (err error) {
 
 // GetSignURL 文件访问签名
 func (c *Store) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
-	if len(expire) > 0 {
-		c.localAdapter.GetSignURL(ctx, object, expire...)
-	}
-	return c.localAdapter.GetSignURL(ctx, object)
+	return c.localAdapter.GetSignURL(ctx, object, expire...)
+}
+
+// GetUploadSignURL 生成客户端直传的签名，适配器不支持时返回 ErrUnsupported
+func (c *Store) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	return uploadSignURL(ctx, c.localAdapter, object, expire, opts)
+}
+
+// PostPolicy 生成表单上传策略，适配器不支持时返回 ErrUnsupported
+func (c *Store) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	return postPolicy(ctx, c.localAdapter, opts)
 }
 
 // IsExist 判断文件是否存在
@@ -55,24 +84,86 @@ func (c *Store) IsExist(ctx context.Context, object string) (err error) {
 	return c.localAdapter.IsExist(ctx, object)
 }
 
+// Exists 判断文件是否存在，文件不存在时返回 false 而不是错误
+func (c *Store) Exists(ctx context.Context, object string) (exists bool, err error) {
+	err = c.localAdapter.IsExist(ctx, object)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // Lists 文件前缀，列出文件
 func (c *Store) Lists(ctx context.Context, prefix string) (files []*File, err error) {
 	return c.localAdapter.Lists(ctx, prefix)
 }
 
-// Upload 上传文件
+// ListPage 文件前缀，分页列出文件，token 为上一页返回的 NextToken，首页传空
+func (c *Store) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	return listPage(ctx, c.localAdapter, prefix, token, limit)
+}
+
+// Walk 文件前缀，逐页遍历文件，fn 返回错误时停止遍历并返回该错误
+func (c *Store) Walk(ctx context.Context, prefix string, fn func(file *File) error) (err error) {
+	return walkFiles(ctx, c.localAdapter, prefix, fn)
+}
+
+// ListDir 列出目录下的文件和子目录，不递归
+func (c *Store) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return listDir(ctx, c.localAdapter, prefix)
+}
+
+// Upload 上传文件，文件大小超过分片上传阈值或大小未知(-1)时，自动使用分片上传
 func (c *Store) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	if c.useMultipart(size) {
+		return c.multipartUpload(ctx, path, reader, size, mergeHeaders(headers...))
+	}
 	if len(headers) > 0 {
 		return c.localAdapter.Upload(ctx, path, reader, size, headers...)
 	}
 	return c.localAdapter.Upload(ctx, path, reader, size)
 }
 
+// MultipartUpload 分片上传文件，适配器不支持分片上传时直接上传
+func (c *Store) MultipartUpload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return c.multipartUpload(ctx, path, reader, size, mergeHeaders(headers...))
+}
+
+// ResumableUpload 可断点续传的分片上传，失败时返回的 checkpointID 可用于 ResumeUpload 继续上传
+func (c *Store) ResumableUpload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (checkpointID string, err error) {
+	return c.resumableUpload(ctx, path, reader, size, mergeHeaders(headers...))
+}
+
+// ResumeUpload 从断点继续上传，reader 需要从文件开头读取，已上传的部分会被跳过
+func (c *Store) ResumeUpload(ctx context.Context, checkpointID string, reader io.Reader) (err error) {
+	return c.resumeUpload(ctx, checkpointID, reader)
+}
+
+// AbortUpload 取消断点续传，清理已上传的分片和进度
+func (c *Store) AbortUpload(ctx context.Context, checkpointID string) (err error) {
+	return c.abortUpload(ctx, checkpointID)
+}
+
 // Download 下载文件
 func (c *Store) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	return c.localAdapter.Download(ctx, object)
 }
 
+// DownloadRange 下载文件的指定范围，length 小于等于0时下载到文件末尾
+func (c *Store) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	return downloadRange(ctx, c.localAdapter, object, offset, length)
+}
+
+// Copy 复制文件，适配器不支持服务端复制时，下载后重新上传
+func (c *Store) Copy(ctx context.Context, src, dst string) (err error) {
+	return copyObject(ctx, c.localAdapter, src, dst)
+}
+
+// Move 移动文件，适配器不支持服务端移动时，复制后删除源文件
+func (c *Store) Move(ctx context.Context, src, dst string) (err error) {
+	return moveObject(ctx, c.localAdapter, src, dst)
+}
+
 // GetInfo 获取指定文件信息
 func (c *Store) GetInfo(ctx context.Context, object string) (info *File, err error) {
 	return c.localAdapter.GetInfo(ctx, object)
@@ -24,6 +24,11 @@ func NewStore(adapterType string, cfg interface{}) (*Store, error) {
 	return NewWithAdapter(adapter), nil
 }
 
+// Use 给默认存储器添加中间件，先添加的中间件在最外层
+func Use(mw ...Middleware) {
+	defaultStore.Use(mw...)
+}
+
 // Delete 删除文件
 func Delete(ctx context.Context, object string) (err error) {
 	return defaultStore.Delete(ctx, object)
@@ -36,10 +41,17 @@ func Deletes(ctx context.Context, objects []string) (err error) {
 
 // GetSignURL 文件访问签名
 func GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
-	if len(expire) > 0 {
-		defaultStore.GetSignURL(ctx, object, expire...)
-	}
-	return defaultStore.GetSignURL(ctx, object)
+	return defaultStore.GetSignURL(ctx, object, expire...)
+}
+
+// GetUploadSignURL 生成客户端直传的签名，适配器不支持时返回 ErrUnsupported
+func GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	return defaultStore.GetUploadSignURL(ctx, object, expire, opts)
+}
+
+// PostPolicy 生成表单上传策略，适配器不支持时返回 ErrUnsupported
+func PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	return defaultStore.PostPolicy(ctx, opts)
 }
 
 // IsExist 判断文件是否存在
@@ -47,12 +59,32 @@ func IsExist(ctx context.Context, object string) (err error) {
 	return defaultStore.IsExist(ctx, object)
 }
 
+// Exists 判断文件是否存在，文件不存在时返回 false 而不是错误
+func Exists(ctx context.Context, object string) (exists bool, err error) {
+	return defaultStore.Exists(ctx, object)
+}
+
 // Lists 文件前缀，列出文件
 func Lists(ctx context.Context, prefix string) (files []*File, err error) {
 	return defaultStore.Lists(ctx, prefix)
 }
 
-// Upload 上传文件
+// ListPage 文件前缀，分页列出文件，token 为上一页返回的 NextToken，首页传空
+func ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	return defaultStore.ListPage(ctx, prefix, token, limit)
+}
+
+// Walk 文件前缀，逐页遍历文件，fn 返回错误时停止遍历并返回该错误
+func Walk(ctx context.Context, prefix string, fn func(file *File) error) (err error) {
+	return defaultStore.Walk(ctx, prefix, fn)
+}
+
+// ListDir 列出目录下的文件和子目录，不递归
+func ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	return defaultStore.ListDir(ctx, prefix)
+}
+
+// Upload 上传文件，文件大小超过分片上传阈值或大小未知(-1)时，自动使用分片上传
 func Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
 	if len(headers) > 0 {
 		return defaultStore.Upload(ctx, path, reader, size, headers...)
@@ -60,16 +92,61 @@ func Upload(ctx context.Context, path string, reader io.Reader, size int64, head
 	return defaultStore.Upload(ctx, path, reader, size)
 }
 
+// MultipartUpload 分片上传文件，适配器不支持分片上传时直接上传
+func MultipartUpload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	return defaultStore.MultipartUpload(ctx, path, reader, size, headers...)
+}
+
+// ResumableUpload 可断点续传的分片上传，失败时返回的 checkpointID 可用于 ResumeUpload 继续上传
+func ResumableUpload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (checkpointID string, err error) {
+	return defaultStore.ResumableUpload(ctx, path, reader, size, headers...)
+}
+
+// ResumeUpload 从断点继续上传，reader 需要从文件开头读取，已上传的部分会被跳过
+func ResumeUpload(ctx context.Context, checkpointID string, reader io.Reader) (err error) {
+	return defaultStore.ResumeUpload(ctx, checkpointID, reader)
+}
+
+// AbortUpload 取消断点续传，清理已上传的分片和进度
+func AbortUpload(ctx context.Context, checkpointID string) (err error) {
+	return defaultStore.AbortUpload(ctx, checkpointID)
+}
+
 // Download 下载文件
 func Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
 	return defaultStore.Download(ctx, object)
 }
 
+// DownloadRange 下载文件的指定范围，length 小于等于0时下载到文件末尾
+func DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	return defaultStore.DownloadRange(ctx, object, offset, length)
+}
+
+// Copy 复制文件，适配器不支持服务端复制时，下载后重新上传
+func Copy(ctx context.Context, src, dst string) (err error) {
+	return defaultStore.Copy(ctx, src, dst)
+}
+
+// Move 移动文件，适配器不支持服务端移动时，复制后删除源文件
+func Move(ctx context.Context, src, dst string) (err error) {
+	return defaultStore.Move(ctx, src, dst)
+}
+
 // GetInfo 获取指定文件信息
 func GetInfo(ctx context.Context, object string) (info *File, err error) {
 	return defaultStore.GetInfo(ctx, object)
 }
 
+// FS 返回只读的 io/fs 文件系统，ctx 用于文件系统的全部请求
+func FS(ctx context.Context) StoreFS {
+	return defaultStore.FS(ctx)
+}
+
+// DownloadArchive 把多个文件边下载边打包写入 w，返回下载失败而被跳过的文件
+func DownloadArchive(ctx context.Context, opts *ArchiveOptions, w io.Writer) (failed []*ArchiveError, err error) {
+	return defaultStore.DownloadArchive(ctx, opts, w)
+}
+
 func PingTest(ctx context.Context) (err error) {
 	return defaultStore.PingTest(ctx)
 }
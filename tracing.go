@@ -0,0 +1,207 @@
+package filesys
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+)
+
+// tracerName 链路追踪使用的 Tracer 名称
+const tracerName = "github.com/kennylixi/go-filesys"
+
+// 链路追踪的属性
+const (
+	traceAdapter     = attribute.Key("filesys.adapter")     // 适配器类型
+	traceBucket      = attribute.Key("filesys.bucket")      // 存储桶
+	traceObject      = attribute.Key("filesys.object")      // 文件路径
+	traceObjects     = attribute.Key("filesys.objects")     // 批量删除的文件路径
+	traceDestination = attribute.Key("filesys.destination") // 复制、移动的目标路径
+	tracePrefix      = attribute.Key("filesys.prefix")      // 列出文件的前缀
+	traceSize        = attribute.Key("filesys.size")        // 文件大小或上传的字节数
+	traceOffset      = attribute.Key("filesys.offset")      // 分段下载的偏移量
+	traceLength      = attribute.Key("filesys.length")      // 分段下载的长度
+	traceUploadID    = attribute.Key("filesys.upload_id")   // 分片上传的ID
+	tracePartNumber  = attribute.Key("filesys.part_number") // 分片序号
+	traceErrorClass  = attribute.Key("filesys.error_class") // 错误分类
+)
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	TracerProvider trace.TracerProvider // 为空时使用 otel.GetTracerProvider()
+	Adapter        string               // filesys.adapter 属性的值，为空时根据适配器类型生成，例如 OssAdapter 为 oss
+}
+
+// NewTracingMiddleware OpenTelemetry 链路追踪中间件，每次调用适配器时创建一个 span，
+// span 的父节点取自传入的 ctx，并把带有 span 的 ctx 传给被包装的适配器
+func NewTracingMiddleware(cfg ...*TracingConfig) Middleware {
+	config := &TracingConfig{}
+	if len(cfg) > 0 && cfg[0] != nil {
+		config = cfg[0]
+	}
+	return func(next Adapter) Adapter {
+		provider := config.TracerProvider
+		if provider == nil {
+			provider = otel.GetTracerProvider()
+		}
+		name := config.Adapter
+		if name == "" {
+			name = adapterName(next)
+		}
+		attrs := []attribute.KeyValue{traceAdapter.String(name)}
+		if bucket, ok := innerAdapter(next).(BucketAdapter); ok {
+			attrs = append(attrs, traceBucket.String(bucket.Bucket()))
+		}
+		return &tracingAdapter{
+			AdapterWrapper: AdapterWrapper{Next: next},
+			tracer:         provider.Tracer(tracerName),
+			attrs:          attrs,
+		}
+	}
+}
+
+// tracingAdapter 链路追踪中间件
+type tracingAdapter struct {
+	AdapterWrapper
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue // 每个 span 都有的属性
+}
+
+// start 创建 span，需要 defer 调用 endSpan 结束
+func (t *tracingAdapter) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "filesys."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan 记录错误并结束 span
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+		span.SetAttributes(traceErrorClass.String(errorClass(*err)))
+	}
+	span.End()
+}
+
+func (t *tracingAdapter) Delete(ctx context.Context, objects ...string) (err error) {
+	ctx, span := t.start(ctx, "Delete", traceObjects.StringSlice(objects))
+	defer endSpan(span, &err)
+	return t.Next.Delete(ctx, objects...)
+}
+
+func (t *tracingAdapter) GetSignURL(ctx context.Context, object string, expire ...int64) (link string, err error) {
+	ctx, span := t.start(ctx, "GetSignURL", traceObject.String(object))
+	defer endSpan(span, &err)
+	return t.Next.GetSignURL(ctx, object, expire...)
+}
+
+func (t *tracingAdapter) IsExist(ctx context.Context, object string) (err error) {
+	ctx, span := t.start(ctx, "IsExist", traceObject.String(object))
+	defer endSpan(span, &err)
+	return t.Next.IsExist(ctx, object)
+}
+
+func (t *tracingAdapter) Lists(ctx context.Context, prefix string) (files []*File, err error) {
+	ctx, span := t.start(ctx, "Lists", tracePrefix.String(prefix))
+	defer endSpan(span, &err)
+	return t.Next.Lists(ctx, prefix)
+}
+
+func (t *tracingAdapter) Upload(ctx context.Context, path string, reader io.Reader, size int64, headers ...map[string]string) (err error) {
+	ctx, span := t.start(ctx, "Upload", traceObject.String(path), traceSize.Int64(size))
+	defer endSpan(span, &err)
+	return t.Next.Upload(ctx, path, reader, size, headers...)
+}
+
+func (t *tracingAdapter) Download(ctx context.Context, object string) (body io.ReadCloser, err error) {
+	ctx, span := t.start(ctx, "Download", traceObject.String(object))
+	defer endSpan(span, &err)
+	return t.Next.Download(ctx, object)
+}
+
+func (t *tracingAdapter) GetInfo(ctx context.Context, object string) (info *File, err error) {
+	ctx, span := t.start(ctx, "GetInfo", traceObject.String(object))
+	defer endSpan(span, &err)
+	if info, err = t.Next.GetInfo(ctx, object); err == nil {
+		span.SetAttributes(traceSize.Int64(info.Size))
+	}
+	return
+}
+
+func (t *tracingAdapter) ListPage(ctx context.Context, prefix, token string, limit int) (result *ListResult, err error) {
+	ctx, span := t.start(ctx, "ListPage", tracePrefix.String(prefix))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.ListPage(ctx, prefix, token, limit)
+}
+
+func (t *tracingAdapter) ListDir(ctx context.Context, prefix string) (files []*File, err error) {
+	ctx, span := t.start(ctx, "ListDir", tracePrefix.String(prefix))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.ListDir(ctx, prefix)
+}
+
+func (t *tracingAdapter) DownloadRange(ctx context.Context, object string, offset, length int64) (body io.ReadCloser, err error) {
+	ctx, span := t.start(ctx, "DownloadRange", traceObject.String(object), traceOffset.Int64(offset), traceLength.Int64(length))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.DownloadRange(ctx, object, offset, length)
+}
+
+func (t *tracingAdapter) Copy(ctx context.Context, src, dst string) (err error) {
+	ctx, span := t.start(ctx, "Copy", traceObject.String(src), traceDestination.String(dst))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.Copy(ctx, src, dst)
+}
+
+func (t *tracingAdapter) Move(ctx context.Context, src, dst string) (err error) {
+	ctx, span := t.start(ctx, "Move", traceObject.String(src), traceDestination.String(dst))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.Move(ctx, src, dst)
+}
+
+func (t *tracingAdapter) InitMultipart(ctx context.Context, object string, headers map[string]string) (uploadID string, err error) {
+	ctx, span := t.start(ctx, "InitMultipart", traceObject.String(object))
+	defer endSpan(span, &err)
+	if uploadID, err = t.AdapterWrapper.InitMultipart(ctx, object, headers); err == nil {
+		span.SetAttributes(traceUploadID.String(uploadID))
+	}
+	return
+}
+
+func (t *tracingAdapter) UploadPart(ctx context.Context, object, uploadID string, number int, reader io.Reader, size int64) (part *Part, err error) {
+	ctx, span := t.start(ctx, "UploadPart", traceObject.String(object), traceUploadID.String(uploadID), tracePartNumber.Int(number), traceSize.Int64(size))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.UploadPart(ctx, object, uploadID, number, reader, size)
+}
+
+func (t *tracingAdapter) CompleteMultipart(ctx context.Context, object, uploadID string, parts []*Part) (err error) {
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+	}
+	ctx, span := t.start(ctx, "CompleteMultipart", traceObject.String(object), traceUploadID.String(uploadID), traceSize.Int64(size))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.CompleteMultipart(ctx, object, uploadID, parts)
+}
+
+func (t *tracingAdapter) AbortMultipart(ctx context.Context, object, uploadID string) (err error) {
+	ctx, span := t.start(ctx, "AbortMultipart", traceObject.String(object), traceUploadID.String(uploadID))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.AbortMultipart(ctx, object, uploadID)
+}
+
+func (t *tracingAdapter) GetUploadSignURL(ctx context.Context, object string, expire int64, opts *UploadSignOptions) (sign *UploadSign, err error) {
+	ctx, span := t.start(ctx, "GetUploadSignURL", traceObject.String(object))
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.GetUploadSignURL(ctx, object, expire, opts)
+}
+
+func (t *tracingAdapter) PostPolicy(ctx context.Context, opts *PostPolicyOptions) (policy *PostPolicyResult, err error) {
+	ctx, span := t.start(ctx, "PostPolicy")
+	defer endSpan(span, &err)
+	return t.AdapterWrapper.PostPolicy(ctx, opts)
+}
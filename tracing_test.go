@@ -0,0 +1,136 @@
+package filesys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ctxAdapter 记录传给适配器的 ctx
+type ctxAdapter struct {
+	*MemoryAdapter
+	ctx context.Context
+}
+
+func (a *ctxAdapter) GetInfo(ctx context.Context, object string) (*File, error) {
+	a.ctx = ctx
+	return a.MemoryAdapter.GetInfo(ctx, object)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	mw := NewTracingMiddleware(&TracingConfig{TracerProvider: provider})
+
+	inner := &ctxAdapter{MemoryAdapter: newMemoryAdapter(t, map[string]string{"a.txt": "hello"})}
+	adapter := mw(inner)
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	if _, err := adapter.GetInfo(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.GetInfo(ctx, "missing.txt"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("GetInfo missing file: %v", err)
+	}
+	if err := adapter.Upload(ctx, "b.txt", strings.NewReader("world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 4", len(spans))
+	}
+	// 被包装的适配器收到的是 GetInfo 的 span
+	if got := trace.SpanContextFromContext(inner.ctx).SpanID(); got != spans[1].SpanContext().SpanID() {
+		t.Errorf("inner adapter span = %s, want %s", got, spans[1].SpanContext().SpanID())
+	}
+	tests := []struct {
+		name   string
+		attrs  map[attribute.Key]attribute.Value
+		status codes.Code
+	}{
+		{"filesys.GetInfo", map[attribute.Key]attribute.Value{
+			traceAdapter: attribute.StringValue("ctx"),
+			traceObject:  attribute.StringValue("a.txt"),
+		}, codes.Unset},
+		{"filesys.GetInfo", map[attribute.Key]attribute.Value{
+			traceObject:     attribute.StringValue("missing.txt"),
+			traceErrorClass: attribute.StringValue("not_exist"),
+		}, codes.Error},
+		{"filesys.Upload", map[attribute.Key]attribute.Value{
+			traceObject: attribute.StringValue("b.txt"),
+			traceSize:   attribute.Int64Value(5),
+		}, codes.Unset},
+	}
+	for i, tt := range tests {
+		span := spans[i]
+		if span.Name() != tt.name {
+			t.Errorf("span %d name = %s, want %s", i, span.Name(), tt.name)
+		}
+		if span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %d kind = %s, want client", i, span.SpanKind())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %d is not a child of the parent span", i)
+		}
+		attrs := spanAttributes(span)
+		if _, ok := attrs[traceBucket]; ok {
+			t.Errorf("span %d has a bucket attribute for an adapter without bucket", i)
+		}
+		for key, want := range tt.attrs {
+			if got, ok := attrs[key]; !ok || got != want {
+				t.Errorf("span %d %s = %v, want %v", i, key, got.Emit(), want.Emit())
+			}
+		}
+		if span.Status().Code != tt.status {
+			t.Errorf("span %d status = %v, want %v", i, span.Status().Code, tt.status)
+		}
+	}
+	if events := spans[1].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("error span events = %v, want one exception", events)
+	}
+}
+
+func TestTracingMiddlewareBucket(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	minio, err := NewAdapterMinio(ConfigMinio{AccessKey: "ak", SecretKey: "sk", Endpoint: "127.0.0.1:9000", Bucket: "photos"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := NewTracingMiddleware(&TracingConfig{TracerProvider: provider, Adapter: "storage"})(minio)
+	if _, err = adapter.GetSignURL(context.Background(), "a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "filesys.GetSignURL" {
+		t.Fatalf("spans = %v, want one filesys.GetSignURL", spans)
+	}
+	attrs := spanAttributes(spans[0])
+	want := map[attribute.Key]attribute.Value{
+		traceAdapter: attribute.StringValue("storage"),
+		traceBucket:  attribute.StringValue("photos"),
+		traceObject:  attribute.StringValue("a.jpg"),
+	}
+	for key, value := range want {
+		if got := attrs[key]; got != value {
+			t.Errorf("%s = %v, want %v", key, got.Emit(), value.Emit())
+		}
+	}
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
@@ -60,3 +60,14 @@ func toJSON(v interface{}) (jsonStr string) {
 	}
 	return string(p)
 }
+
+// comparePath 按目录层级逐级比较两个路径，与 filepath.WalkDir 的遍历顺序一致
+func comparePath(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return len(as) - len(bs)
+}